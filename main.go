@@ -5,9 +5,11 @@ import (
 	"log"
 	"net/url"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"golang.org/x/exp/slices"
@@ -117,13 +119,79 @@ func deb(context *cli.Context) error {
 		return fmt.Errorf("no package provided to install")
 	}
 
-	packageDefinitions, err := xdeb.FindPackage(packageName, path, provider, distribution, true)
+	// support APT-style <package>=<version> as well
+	packageName, packageVersion, _ := strings.Cut(packageName, "=")
+
+	if len(context.String("package-version")) > 0 {
+		packageVersion = context.String("package-version")
+	}
+
+	config, err := xdeb.ParseConfig()
+
+	if err != nil {
+		return err
+	}
+
+	interactive := context.Bool("interactive")
+
+	if interactive && !xdeb.IsTerminal(os.Stdin) {
+		xdeb.LogMessage("Standard input is not a terminal, disabling interactive mode")
+		interactive = false
+	}
+
+	// the picker offers packages starting with the name provided as well
+	matchMode := xdeb.PACKAGE_MATCH_EXACT
+
+	if interactive {
+		matchMode = xdeb.PACKAGE_MATCH_PREFIX
+	}
+
+	packageDefinitions, err := xdeb.FindPackage(packageName, path, provider, distribution, matchMode)
 
 	if err != nil {
 		return err
 	}
 
-	return xdeb.InstallPackage(packageDefinitions[0], context)
+	if len(packageVersion) > 0 {
+		packageDefinitions, err = xdeb.FilterPackageVersion(packageDefinitions, packageVersion)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if interactive {
+		return pickAndInstall(context, config, packageDefinitions, provider, distribution)
+	}
+
+	packageDefinition, err := config.Policy.SelectPackage(packageDefinitions, provider, distribution)
+
+	if err != nil {
+		return err
+	}
+
+	return xdeb.InstallPackage(packageDefinition, context)
+}
+
+func pickAndInstall(context *cli.Context, config *xdeb.XdebInstallConfig, packageDefinitions []*xdeb.XdebPackageDefinition, provider string, distribution string) error {
+	selected, err := xdeb.PickPackages(&config.Policy, packageDefinitions, provider, distribution, os.Stdin, os.Stdout)
+
+	if err != nil {
+		return err
+	}
+
+	if len(selected) == 0 {
+		xdeb.LogMessage("No packages selected, nothing to install")
+		return nil
+	}
+
+	for _, packageDefinition := range selected {
+		if err := xdeb.InstallPackage(packageDefinition, context); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func file(context *cli.Context, filePath string) error {
@@ -183,6 +251,29 @@ func file(context *cli.Context, filePath string) error {
 	return xdeb.InstallPackage(&packageDefinition, context)
 }
 
+func searchMatchMode(context *cli.Context) (string, error) {
+	matchMode := xdeb.PACKAGE_MATCH_PREFIX
+	flags := []string{}
+
+	for _, mode := range []string{xdeb.PACKAGE_MATCH_EXACT, xdeb.PACKAGE_MATCH_CONTAINS, xdeb.PACKAGE_MATCH_GLOB, xdeb.PACKAGE_MATCH_REGEX} {
+		if context.Bool(mode) {
+			matchMode = mode
+			flags = append(flags, fmt.Sprintf("--%s", mode))
+		}
+	}
+
+	if len(flags) > 1 {
+		return "", fmt.Errorf("flags %v are mutually exclusive", flags)
+	}
+
+	return matchMode, nil
+}
+
+func descriptionSummary(description string) string {
+	summary, _, _ := strings.Cut(description, "\n")
+	return summary
+}
+
 func search(context *cli.Context) error {
 	packageName := context.Args().First()
 
@@ -190,6 +281,13 @@ func search(context *cli.Context) error {
 		return fmt.Errorf("no package provided to search for")
 	}
 
+	searchDescriptions := context.Bool("description")
+	matchMode, err := searchMatchMode(context)
+
+	if err != nil {
+		return err
+	}
+
 	path, err := xdeb.RepositoryPath()
 
 	if err != nil {
@@ -208,13 +306,67 @@ func search(context *cli.Context) error {
 		return err
 	}
 
-	packageDefinitions, err := xdeb.FindPackage(packageName, path, provider, distribution, context.Bool("exact"))
+	var packageDefinitions []*xdeb.XdebPackageDefinition
+
+	if searchDescriptions {
+		packageDefinitions, err = xdeb.SearchDescriptions(context.Args().Slice(), path, provider, distribution)
+	} else {
+		packageDefinitions, err = xdeb.FindPackage(packageName, path, provider, distribution, matchMode)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if context.Bool("latest-only") {
+		packageDefinitions = xdeb.LatestPackageDefinitions(packageDefinitions)
+	}
+
+	if limit := context.Int("limit"); limit > 0 && len(packageDefinitions) > limit {
+		packageDefinitions = packageDefinitions[:limit]
+	}
+
+	installState, err := xdeb.LoadInstallState()
 
 	if err != nil {
 		return err
 	}
 
-	for _, packageDefinition := range packageDefinitions {
+	results := installState.SearchResults(packageDefinitions)
+	outputFormat := context.String("output")
+
+	if xdeb.IsStructuredOutput(outputFormat) {
+		return xdeb.PrintStructured(outputFormat, results)
+	}
+
+	if outputFormat == xdeb.OUTPUT_FORMAT_TABLE {
+		header := []string{"PROVIDER", "DISTRIBUTION", "COMPONENT", "PACKAGE", "VERSION", "STATUS"}
+		rows := [][]string{}
+
+		if searchDescriptions {
+			header = append(header, "DESCRIPTION")
+		}
+
+		for _, result := range results {
+			packageDefinition := &result.XdebPackageDefinition
+			row := []string{
+				packageDefinition.Provider, packageDefinition.Distribution, packageDefinition.Component,
+				packageDefinition.Name, packageDefinition.Version, result.Status.String(),
+			}
+
+			if searchDescriptions {
+				row = append(row, descriptionSummary(packageDefinition.Description))
+			}
+
+			rows = append(rows, row)
+		}
+
+		return xdeb.PrintTable(header, rows)
+	}
+
+	for _, result := range results {
+		packageDefinition := &result.XdebPackageDefinition
+
 		fmt.Printf("%s/%s\n", packageDefinition.Provider, packageDefinition.Component)
 		fmt.Printf("  package: %s\n", packageDefinition.Name)
 		fmt.Printf("  distribution: %s\n", packageDefinition.Distribution)
@@ -223,6 +375,12 @@ func search(context *cli.Context) error {
 			fmt.Printf("  version: %s\n", packageDefinition.Version)
 		}
 
+		fmt.Printf("  status: %s\n", result.Status)
+
+		if searchDescriptions && len(packageDefinition.Description) > 0 {
+			fmt.Printf("  description: %s\n", descriptionSummary(packageDefinition.Description))
+		}
+
 		fmt.Printf("  url: %s\n", packageDefinition.Url)
 
 		if len(packageDefinition.Sha256) > 0 {
@@ -235,13 +393,456 @@ func search(context *cli.Context) error {
 	return nil
 }
 
-func sync(context *cli.Context) error {
-	lists, err := xdeb.ParsePackageLists()
+func policy(context *cli.Context) error {
+	packageName := strings.Trim(context.Args().First(), " ")
+
+	if len(packageName) == 0 {
+		return fmt.Errorf("no package provided to explain the policy for")
+	}
+
+	path, err := xdeb.RepositoryPath()
+
+	if err != nil {
+		return err
+	}
+
+	provider, err := findProvider(context.String("provider"))
+
+	if err != nil {
+		return err
+	}
+
+	distribution, err := findDistribution(provider, context.String("distribution"))
+
+	if err != nil {
+		return err
+	}
+
+	config, err := xdeb.ParseConfig()
 
 	if err != nil {
 		return err
 	}
 
+	packageDefinitions, err := xdeb.FindPackage(packageName, path, provider, distribution, xdeb.PACKAGE_MATCH_EXACT)
+
+	if err != nil {
+		return err
+	}
+
+	candidates := config.Policy.EvaluatePackages(packageDefinitions, provider, distribution)
+	outputFormat := context.String("output")
+
+	if xdeb.IsStructuredOutput(outputFormat) {
+		return xdeb.PrintStructured(outputFormat, candidates)
+	}
+
+	if outputFormat == xdeb.OUTPUT_FORMAT_TABLE {
+		rows := [][]string{}
+
+		for _, candidate := range candidates {
+			selected := "no"
+
+			if candidate.Selected {
+				selected = "yes"
+			}
+
+			rows = append(rows, []string{
+				candidate.Package.Provider, candidate.Package.Distribution, candidate.Package.Component,
+				candidate.Package.Version, strconv.Itoa(candidate.Priority), selected, candidate.Reason,
+			})
+		}
+
+		return xdeb.PrintTable([]string{"PROVIDER", "DISTRIBUTION", "COMPONENT", "VERSION", "PRIORITY", "SELECTED", "REASON"}, rows)
+	}
+
+	for _, candidate := range candidates {
+		if candidate.Selected {
+			fmt.Print("[selected] ")
+		}
+
+		fmt.Printf("%s/%s\n", candidate.Package.Provider, candidate.Package.Component)
+		fmt.Printf("  package: %s\n", candidate.Package.Name)
+		fmt.Printf("  distribution: %s\n", candidate.Package.Distribution)
+
+		if len(candidate.Package.Version) > 0 {
+			fmt.Printf("  version: %s\n", candidate.Package.Version)
+		}
+
+		if candidate.Pin != nil {
+			fmt.Printf("  priority: %d (pin: %s)\n", candidate.Priority, candidate.Pin)
+		} else {
+			fmt.Printf("  priority: %d\n", candidate.Priority)
+		}
+
+		fmt.Printf("  url: %s\n", candidate.Package.Url)
+		fmt.Printf("  reason: %s\n", candidate.Reason)
+		fmt.Println()
+	}
+
+	return nil
+}
+
+func show(context *cli.Context) error {
+	packageName := strings.Trim(context.Args().First(), " ")
+
+	if len(packageName) == 0 {
+		return fmt.Errorf("no package provided to show")
+	}
+
+	path, err := xdeb.RepositoryPath()
+
+	if err != nil {
+		return err
+	}
+
+	provider, err := findProvider(context.String("provider"))
+
+	if err != nil {
+		return err
+	}
+
+	distribution, err := findDistribution(provider, context.String("distribution"))
+
+	if err != nil {
+		return err
+	}
+
+	config, err := xdeb.ParseConfig()
+
+	if err != nil {
+		return err
+	}
+
+	packageDefinitions, err := xdeb.FindPackage(packageName, path, provider, distribution, xdeb.PACKAGE_MATCH_EXACT)
+
+	if err != nil {
+		return err
+	}
+
+	packageDefinition, err := config.Policy.SelectPackage(packageDefinitions, provider, distribution)
+
+	if err != nil {
+		return err
+	}
+
+	installState, err := xdeb.LoadInstallState()
+
+	if err != nil {
+		return err
+	}
+
+	installed := installState.Record(packageDefinition.Name)
+	status := installState.Status(packageDefinition)
+
+	// a single package has no tabular representation, table output is the same as plain output
+	if outputFormat := context.String("output"); xdeb.IsStructuredOutput(outputFormat) {
+		return xdeb.PrintStructured(outputFormat, &xdeb.XdebPackageDetails{
+			XdebPackageDefinition: *packageDefinition,
+			Installed:             installed,
+			Status:                status,
+		})
+	}
+
+	fmt.Printf("%s/%s\n", packageDefinition.Provider, packageDefinition.Component)
+	fmt.Printf("  package: %s\n", packageDefinition.Name)
+	fmt.Printf("  distribution: %s\n", packageDefinition.Distribution)
+
+	details := [][]string{
+		{"version", packageDefinition.Version},
+		{"section", packageDefinition.Section},
+		{"maintainer", packageDefinition.Maintainer},
+		{"homepage", packageDefinition.Homepage},
+		{"pre-depends", packageDefinition.PreDepends},
+		{"depends", packageDefinition.Depends},
+	}
+
+	if packageDefinition.Size > 0 {
+		details = append(details, []string{"size", xdeb.FormatByteSize(packageDefinition.Size)})
+	}
+
+	if packageDefinition.InstalledSize > 0 {
+		details = append(details, []string{"installed size", xdeb.FormatByteSize(packageDefinition.InstalledSize)})
+	}
+
+	details = append(details, []string{"url", packageDefinition.Url}, []string{"sha256", packageDefinition.Sha256})
+
+	for _, detail := range details {
+		if len(detail[1]) > 0 {
+			fmt.Printf("  %s: %s\n", detail[0], detail[1])
+		}
+	}
+
+	if len(packageDefinition.PostInstall) > 0 {
+		fmt.Println("  post-install:")
+
+		for _, postInstallHook := range packageDefinition.PostInstall {
+			fmt.Printf("    %s\n", postInstallHook.Name)
+
+			for _, command := range postInstallHook.Commands {
+				if command.Root {
+					fmt.Printf("      (root) %s\n", command.Command)
+				} else {
+					fmt.Printf("      %s\n", command.Command)
+				}
+			}
+		}
+	}
+
+	if installed != nil {
+		if len(installed.Version) > 0 {
+			fmt.Printf("  installed: %s via xdeb-install", installed.Version)
+		} else {
+			fmt.Print("  installed: via xdeb-install")
+		}
+
+		fmt.Printf(" (%s @ %s/%s)\n", installed.Provider, installed.Distribution, installed.Component)
+	} else if status.Status == xdeb.INSTALL_STATUS_NATIVE {
+		fmt.Printf("  installed: %s via xbps\n", status.Version)
+	} else {
+		fmt.Println("  installed: no")
+	}
+
+	fmt.Printf("  status: %s\n", status)
+
+	if len(packageDefinition.Description) > 0 {
+		fmt.Println("  description:")
+
+		for _, line := range strings.Split(packageDefinition.Description, "\n") {
+			fmt.Printf("    %s\n", line)
+		}
+	}
+
+	return nil
+}
+
+func versions(context *cli.Context) error {
+	packageName := strings.Trim(context.Args().First(), " ")
+
+	if len(packageName) == 0 {
+		return fmt.Errorf("no package provided to list versions for")
+	}
+
+	path, err := xdeb.RepositoryPath()
+
+	if err != nil {
+		return err
+	}
+
+	provider, err := findProvider(context.String("provider"))
+
+	if err != nil {
+		return err
+	}
+
+	distribution, err := findDistribution(provider, context.String("distribution"))
+
+	if err != nil {
+		return err
+	}
+
+	packageDefinitions, err := xdeb.FindPackage(packageName, path, provider, distribution, xdeb.PACKAGE_MATCH_EXACT)
+
+	if err != nil {
+		return err
+	}
+
+	groups := xdeb.GroupPackageVersions(packageDefinitions)
+	outputFormat := context.String("output")
+
+	if xdeb.IsStructuredOutput(outputFormat) {
+		return xdeb.PrintStructured(outputFormat, groups)
+	}
+
+	if outputFormat == xdeb.OUTPUT_FORMAT_TABLE {
+		rows := [][]string{}
+
+		for _, group := range groups {
+			for _, packageDefinition := range group.Versions {
+				rows = append(rows, []string{
+					group.Provider, group.Distribution, packageDefinition.Component, group.Name, packageDefinition.Version,
+				})
+			}
+		}
+
+		return xdeb.PrintTable([]string{"PROVIDER", "DISTRIBUTION", "COMPONENT", "PACKAGE", "VERSION"}, rows)
+	}
+
+	for _, group := range groups {
+		fmt.Printf("%s/%s\n", group.Provider, group.Distribution)
+		fmt.Printf("  package: %s\n", group.Name)
+
+		for _, packageDefinition := range group.Versions {
+			if len(packageDefinition.Version) > 0 {
+				fmt.Printf("    %s (%s)\n", packageDefinition.Version, packageDefinition.Component)
+			} else {
+				fmt.Printf("    unversioned (%s)\n", packageDefinition.Component)
+			}
+		}
+
+		fmt.Println()
+	}
+
+	return nil
+}
+
+func dependencyTree(context *cli.Context, reverse bool) error {
+	packageName := strings.Trim(context.Args().First(), " ")
+
+	if len(packageName) == 0 {
+		return fmt.Errorf("no package provided to display dependencies for")
+	}
+
+	format := context.String("format")
+
+	if !slices.Contains(dependencyTreeFormats, format) {
+		return fmt.Errorf("format '%s' not supported, use any of %v", format, dependencyTreeFormats)
+	}
+
+	if format == "dot" {
+		xdeb.SetLogOutput(os.Stderr)
+	}
+
+	path, err := xdeb.RepositoryPath()
+
+	if err != nil {
+		return err
+	}
+
+	provider, err := findProvider(context.String("provider"))
+
+	if err != nil {
+		return err
+	}
+
+	distribution, err := findDistribution(provider, context.String("distribution"))
+
+	if err != nil {
+		return err
+	}
+
+	config, err := xdeb.ParseConfig()
+
+	if err != nil {
+		return err
+	}
+
+	packageDefinitions, err := xdeb.FindPackage(packageName, path, provider, distribution, xdeb.PACKAGE_MATCH_EXACT)
+
+	if err != nil {
+		return err
+	}
+
+	packageDefinition, err := config.Policy.SelectPackage(packageDefinitions, provider, distribution)
+
+	if err != nil {
+		return err
+	}
+
+	// dependencies are resolved within the provider and distribution of the chosen candidate
+	packageDefinitions, err = xdeb.LoadPackageDefinitions(path, packageDefinition.Provider, packageDefinition.Distribution)
+
+	if err != nil {
+		return err
+	}
+
+	tree, err := xdeb.BuildDependencyTree(packageDefinition, packageDefinitions, context.Int("depth"), reverse)
+
+	if err != nil {
+		return err
+	}
+
+	// trees have no tabular representation, table output is the same as plain output
+	if outputFormat := context.String("output"); xdeb.IsStructuredOutput(outputFormat) {
+		return xdeb.PrintStructured(outputFormat, tree)
+	}
+
+	if format == "dot" {
+		tree.WriteDot(os.Stdout)
+		return nil
+	}
+
+	fmt.Printf("%s/%s/%s\n", packageDefinition.Provider, packageDefinition.Distribution, packageDefinition.Component)
+	tree.WritePlain(os.Stdout, 1)
+	return nil
+}
+
+func depends(context *cli.Context) error {
+	return dependencyTree(context, false)
+}
+
+func rdepends(context *cli.Context) error {
+	return dependencyTree(context, true)
+}
+
+func provides(context *cli.Context) error {
+	pattern := context.Args().First()
+
+	if len(pattern) == 0 {
+		return fmt.Errorf("no path or regular expression provided to search for")
+	}
+
+	path, err := xdeb.RepositoryPath()
+
+	if err != nil {
+		return err
+	}
+
+	provider, err := findProvider(context.String("provider"))
+
+	if err != nil {
+		return err
+	}
+
+	distribution, err := findDistribution(provider, context.String("distribution"))
+
+	if err != nil {
+		return err
+	}
+
+	matches, err := xdeb.SearchContents(pattern, context.Bool("regex"), path, provider, distribution)
+
+	if err != nil {
+		return err
+	}
+
+	outputFormat := context.String("output")
+
+	if xdeb.IsStructuredOutput(outputFormat) {
+		return xdeb.PrintStructured(outputFormat, matches)
+	}
+
+	if outputFormat == xdeb.OUTPUT_FORMAT_TABLE {
+		rows := [][]string{}
+
+		for _, match := range matches {
+			rows = append(rows, []string{match.Provider, match.Distribution, match.Component, match.Path, strings.Join(match.Packages, ",")})
+		}
+
+		return xdeb.PrintTable([]string{"PROVIDER", "DISTRIBUTION", "COMPONENT", "PATH", "PACKAGES"}, rows)
+	}
+
+	location := ""
+
+	for _, match := range matches {
+		matchLocation := fmt.Sprintf("%s/%s/%s", match.Provider, match.Distribution, match.Component)
+
+		if matchLocation != location {
+			if len(location) > 0 {
+				fmt.Println()
+			}
+
+			fmt.Println(matchLocation)
+			location = matchLocation
+		}
+
+		fmt.Printf("  %s: %s\n", match.Path, strings.Join(match.Packages, ", "))
+	}
+
+	return nil
+}
+
+func sync(context *cli.Context) error {
 	args := context.Args()
 	providerNames := []string{}
 
@@ -250,13 +851,31 @@ func sync(context *cli.Context) error {
 		providerNames = append(providerNames, providerName)
 	}
 
-	err = xdeb.SyncRepositories(lists, providerNames...)
+	lists, err := xdeb.ParsePackageLists(providerNames...)
+
+	if err != nil {
+		return err
+	}
+
+	options := xdeb.SyncOptions{
+		Contents: context.Bool("contents"),
+		Jobs:     context.Int("jobs"),
+	}
+
+	// Ctrl-C cancels all pending downloads, the repositories synced before are kept
+	ctx, stop := signal.NotifyContext(context.Context, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	summary, err := xdeb.SyncRepositories(ctx, lists, options, providerNames...)
 
 	if err != nil {
 		return err
 	}
 
-	xdeb.LogMessage("Finished syncing: %s", strings.ReplaceAll(lists.Path, os.Getenv("HOME"), "~"))
+	xdeb.LogMessage(
+		"Finished syncing: %s (%d components updated, %d unchanged)",
+		strings.ReplaceAll(lists.Path, os.Getenv("HOME"), "~"), summary.Updated, summary.Unchanged,
+	)
 	return nil
 }
 
@@ -268,15 +887,58 @@ func providers(context *cli.Context) error {
 	}
 
 	showDetails := context.Bool("details")
+	outputFormat := context.String("output")
+
+	if xdeb.IsStructuredOutput(outputFormat) {
+		providers := []xdeb.PackageListsProvider{}
+
+		for _, provider := range lists.Providers {
+			provider.Url = xdeb.RedactUrl(provider.RepositoryUrl())
+			provider.Mirrors = slices.Clone(provider.Mirrors)
+
+			for i, mirror := range provider.Mirrors {
+				provider.Mirrors[i] = xdeb.RedactUrl(mirror)
+			}
+
+			if !showDetails {
+				provider.Distributions = nil
+				provider.Components = nil
+			}
+
+			providers = append(providers, provider)
+		}
+
+		return xdeb.PrintStructured(outputFormat, providers)
+	}
+
+	if outputFormat == xdeb.OUTPUT_FORMAT_TABLE {
+		header := []string{"NAME", "ARCHITECTURE", "URL"}
+		rows := [][]string{}
+
+		if showDetails {
+			header = append(header, "DISTRIBUTIONS", "COMPONENTS")
+		}
+
+		for _, provider := range lists.Providers {
+			row := []string{provider.Name, provider.Architecture, xdeb.RedactUrl(provider.RepositoryUrl())}
+
+			if showDetails {
+				row = append(row, strings.Join(provider.Distributions, ","), strings.Join(provider.Components, ","))
+			}
+
+			rows = append(rows, row)
+		}
+
+		return xdeb.PrintTable(header, rows)
+	}
 
 	for _, provider := range lists.Providers {
 		fmt.Println(provider.Name)
 		fmt.Printf("  architecture: %s\n", provider.Architecture)
+		fmt.Printf("  url: %s\n", xdeb.RedactUrl(provider.RepositoryUrl()))
 
-		if provider.Custom {
-			fmt.Printf("  url: %s/%s/%s\n", xdeb.XDEB_INSTALL_REPOSITORIES_URL, xdeb.XDEB_INSTALL_REPOSITORIES_TAG, provider.Url)
-		} else {
-			fmt.Printf("  url: %s\n", provider.Url)
+		for _, mirror := range provider.Mirrors {
+			fmt.Printf("  mirror: %s\n", xdeb.RedactUrl(mirror))
 		}
 
 		if showDetails {
@@ -327,7 +989,7 @@ func prepare(context *cli.Context) error {
 	path := filepath.Join(os.TempDir(), "xdeb-download", "xdeb")
 
 	xdeb.LogMessage("Downloading xdeb [%s] from %s to %s ...", version, requestUrl, path)
-	xdebFile, err := xdeb.DownloadFile(path, requestUrl, false, false)
+	xdebFile, err := xdeb.DownloadFile(path, requestUrl, false)
 
 	if err != nil {
 		return err
@@ -397,6 +1059,14 @@ func clean(context *cli.Context) error {
 		return err
 	}
 
+	if context.Bool("cache") {
+		xdeb.LogMessage("Cleaning up download cache: %s", xdeb.DownloadCachePath())
+
+		if err := xdeb.CleanDownloadCache(); err != nil {
+			return err
+		}
+	}
+
 	if context.Bool("lists") {
 		path, err := xdeb.RepositoryPath()
 
@@ -405,12 +1075,249 @@ func clean(context *cli.Context) error {
 		}
 
 		xdeb.LogMessage("Cleaning up repository path: %s", path)
+
+		if err = os.RemoveAll(xdeb.SyncStagingPath(path)); err != nil {
+			return err
+		}
+
 		return os.RemoveAll(path)
 	}
 
 	return nil
 }
 
+func completion(context *cli.Context) error {
+	script, err := xdeb.CompletionScript(context.Args().First())
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(script)
+	return nil
+}
+
+// the word before the one being completed and the word being completed itself, see xdeb.CompletionScript
+func completionWords() (string, string) {
+	args := os.Args[:len(os.Args)-1]
+	previous, current := "", ""
+
+	if len(args) > 1 {
+		current = args[len(args)-1]
+	}
+
+	if len(args) > 2 {
+		previous = args[len(args)-2]
+	}
+
+	return previous, current
+}
+
+func printCompletions(values []string, current string) {
+	for _, value := range values {
+		if strings.HasPrefix(value, current) {
+			fmt.Println(value)
+		}
+	}
+}
+
+func findFlag(context *cli.Context, name string) cli.Flag {
+	if !strings.HasPrefix(name, "-") || strings.Contains(name, "=") {
+		return nil
+	}
+
+	name = strings.TrimLeft(name, "-")
+
+	for _, flags := range [][]cli.Flag{context.Command.Flags, context.App.Flags} {
+		for _, flag := range flags {
+			if slices.Contains(flag.Names(), name) {
+				return flag
+			}
+		}
+	}
+
+	return nil
+}
+
+func completionProviders() []string {
+	lists, err := xdeb.LoadPackageLists()
+
+	if err != nil {
+		providers, _ := readPath("")
+		return providers
+	}
+
+	providers := []string{}
+
+	for _, provider := range lists.Providers {
+		providers = append(providers, provider.Name)
+	}
+
+	return providers
+}
+
+func completionDistributions(provider string) []string {
+	providers := []string{provider}
+
+	if len(provider) == 0 {
+		providers, _ = readPath("")
+	}
+
+	distributions := []string{}
+
+	for _, provider := range providers {
+		providerDistributions, _ := readPath(provider)
+
+		for _, distribution := range providerDistributions {
+			if !slices.Contains(distributions, distribution) {
+				distributions = append(distributions, distribution)
+			}
+		}
+	}
+
+	slices.Sort(distributions)
+	return distributions
+}
+
+func completionFlagValues(context *cli.Context, flag cli.Flag) []string {
+	switch flag.Names()[0] {
+	case "provider":
+		return completionProviders()
+	case "distribution":
+		return completionDistributions(context.String("provider"))
+	case "output":
+		return xdeb.OUTPUT_FORMATS
+	case "format":
+		return dependencyTreeFormats
+	}
+
+	return nil
+}
+
+func completionPackageNames(context *cli.Context, current string) []string {
+	if context.NArg() > 1 {
+		return nil
+	}
+
+	path, err := xdeb.RepositoryPath()
+
+	if err != nil {
+		return nil
+	}
+
+	names, _ := xdeb.PackageNames(path, current)
+	return names
+}
+
+// values of other flags are left to the shell, e.g. file paths
+func complete(positional func(context *cli.Context, current string) []string) cli.BashCompleteFunc {
+	return func(context *cli.Context) {
+		previous, current := completionWords()
+
+		if flag := findFlag(context, previous); flag != nil {
+			if _, isBool := flag.(*cli.BoolFlag); !isBool {
+				printCompletions(completionFlagValues(context, flag), current)
+				return
+			}
+		}
+
+		if strings.HasPrefix(current, "-") {
+			cli.DefaultCompleteWithFlags(context.Command)(context)
+			return
+		}
+
+		// the command name itself is being completed
+		if context.NArg() == 0 {
+			fmt.Println(current)
+			return
+		}
+
+		printCompletions(positional(context, current), current)
+	}
+}
+
+func completeRoot(context *cli.Context, current string) []string {
+	if context.NArg() > 1 {
+		return nil
+	}
+
+	cli.DefaultCompleteWithFlags(context.Command)(context)
+
+	// listing package names before typing anything would be too noisy
+	if len(current) == 0 {
+		return nil
+	}
+
+	return completionPackageNames(context, current)
+}
+
+func completeNone(context *cli.Context, current string) []string {
+	return nil
+}
+
+func completeProviders(context *cli.Context, current string) []string {
+	return completionProviders()
+}
+
+func completeShells(context *cli.Context, current string) []string {
+	return xdeb.COMPLETION_SHELLS
+}
+
+func setGlobalOptions(context *cli.Context) error {
+	if err := xdeb.SetHttpRetries(context.Int("retries")); err != nil {
+		return err
+	}
+
+	config, err := xdeb.ParseConfig()
+
+	if err != nil {
+		return err
+	}
+
+	if err := xdeb.SetHttpConfig(&config.Http); err != nil {
+		return err
+	}
+
+	if err := setOutputFormat(context); err != nil {
+		return err
+	}
+
+	setProgressMode(context)
+	return nil
+}
+
+// structured output is meant for scripts, which have no use for progress reports
+func setProgressMode(context *cli.Context) {
+	logFile := os.Stdout
+
+	if context.String("output") != xdeb.OUTPUT_FORMAT_PLAIN {
+		logFile = os.Stderr
+	}
+
+	if context.Bool("quiet") || xdeb.IsStructuredOutput(context.String("output")) {
+		xdeb.SetProgressMode(xdeb.PROGRESS_MODE_NONE)
+	} else if xdeb.IsTerminal(logFile) {
+		xdeb.SetProgressMode(xdeb.PROGRESS_MODE_TERMINAL)
+	} else {
+		xdeb.SetProgressMode(xdeb.PROGRESS_MODE_LOG)
+	}
+}
+
+// log messages go to stderr for any output format other than plain, keeping stdout parseable
+func setOutputFormat(context *cli.Context) error {
+	outputFormat := context.String("output")
+
+	if !slices.Contains(xdeb.OUTPUT_FORMATS, outputFormat) {
+		return fmt.Errorf("output format '%s' not supported, use any of %v", outputFormat, xdeb.OUTPUT_FORMATS)
+	}
+
+	if outputFormat != xdeb.OUTPUT_FORMAT_PLAIN {
+		xdeb.SetLogOutput(os.Stderr)
+	}
+
+	return nil
+}
+
 func unixTime(epochString string) (*time.Time, error) {
 	if epochString == "now" {
 		now := time.Now().UTC()
@@ -427,6 +1334,31 @@ func unixTime(epochString string) (*time.Time, error) {
 	return &epoch, nil
 }
 
+var dependencyTreeFormats = []string{"plain", "dot"}
+
+var dependencyTreeFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:    "provider",
+		Usage:   "limit candidates to a specific provider",
+		Aliases: []string{"p"},
+	},
+	&cli.StringFlag{
+		Name:    "distribution",
+		Usage:   "limit candidates to a specific distribution (requires --provider)",
+		Aliases: []string{"dist", "d"},
+	},
+	&cli.IntFlag{
+		Name:  "depth",
+		Usage: "maximum depth of the tree",
+		Value: 3,
+	},
+	&cli.StringFlag{
+		Name:  "format",
+		Usage: fmt.Sprintf("output format, any of %v", dependencyTreeFormats),
+		Value: "plain",
+	},
+}
+
 var (
 	VersionString   string = "dev"
 	VersionCompiled string = "now"
@@ -459,15 +1391,18 @@ func main() {
 	}
 
 	app := &cli.App{
-		Name:        xdeb.APPLICATION_NAME,
-		Usage:       "Automation wrapper for the xdeb utility",
-		UsageText:   fmt.Sprintf("%s [global options (except --file)] <package>\n%s [global options] command [command options] [arguments...]", xdeb.APPLICATION_NAME, xdeb.APPLICATION_NAME),
-		Description: "Simple tool to automatically download, convert, and install DEB packages via the awesome xdeb utility.\nBasically just a wrapper to automate the process.",
-		Version:     VersionString,
-		Compiled:    *compiled,
-		Authors:     authors,
-		Suggest:     true,
-		Action:      deb,
+		Name:                 xdeb.APPLICATION_NAME,
+		Usage:                "Automation wrapper for the xdeb utility",
+		UsageText:            fmt.Sprintf("%s [global options (except --file)] <package>\n%s [global options] command [command options] [arguments...]", xdeb.APPLICATION_NAME, xdeb.APPLICATION_NAME),
+		Description:          "Simple tool to automatically download, convert, and install DEB packages via the awesome xdeb utility.\nBasically just a wrapper to automate the process.",
+		Version:              VersionString,
+		Compiled:             *compiled,
+		Authors:              authors,
+		Suggest:              true,
+		Before:               setGlobalOptions,
+		Action:               deb,
+		BashComplete:         complete(completeRoot),
+		EnableBashCompletion: true,
 		Flags: []cli.Flag{
 			&cli.StringFlag{
 				Name:    "file",
@@ -484,6 +1419,15 @@ func main() {
 				Usage:   "limit search results to a specific distribution (requires --provider)",
 				Aliases: []string{"dist", "d"},
 			},
+			&cli.BoolFlag{
+				Name:    "interactive",
+				Aliases: []string{"i"},
+				Usage:   "pick one or more of the packages starting with the name provided from a numbered list, requires a terminal",
+			},
+			&cli.StringFlag{
+				Name:  "package-version",
+				Usage: "install a specific package version instead of the newest one, same as <package>=<version>",
+			},
 			&cli.StringFlag{
 				Name:    "options",
 				Aliases: []string{"o"},
@@ -496,19 +1440,43 @@ func main() {
 				Usage:   "set the temporary xdeb context root path",
 				Value:   filepath.Join(os.TempDir(), "xdeb"),
 			},
+			&cli.IntFlag{
+				Name:    "jobs",
+				Aliases: []string{"j"},
+				Usage:   "maximum number of repository components synchronized concurrently",
+				Value:   xdeb.SYNC_DEFAULT_JOBS,
+			},
+			&cli.IntFlag{
+				Name:  "retries",
+				Usage: "number of times failed downloads are retried, waiting exponentially longer in between",
+				Value: xdeb.HTTP_DEFAULT_RETRIES,
+			},
+			&cli.BoolFlag{
+				Name:    "quiet",
+				Aliases: []string{"q"},
+				Usage:   "do not report the progress of downloads and syncs",
+			},
+			&cli.StringFlag{
+				Name:    "output",
+				Aliases: []string{"O"},
+				Usage:   fmt.Sprintf("output format of search and info commands, any of %v", xdeb.OUTPUT_FORMATS),
+				Value:   xdeb.OUTPUT_FORMAT_PLAIN,
+			},
 		},
 		Commands: []*cli.Command{
 			{
-				Name:     "xdeb",
-				HelpName: "xdeb [release version]",
-				Usage:    "install the xdeb utility to the system along with its dependencies",
-				Action:   prepare,
+				Name:         "xdeb",
+				HelpName:     "xdeb [release version]",
+				Usage:        "install the xdeb utility to the system along with its dependencies",
+				Action:       prepare,
+				BashComplete: complete(completeNone),
 			},
 			{
-				Name:    "providers",
-				Usage:   "list available providers",
-				Aliases: []string{"p"},
-				Action:  providers,
+				Name:         "providers",
+				Usage:        "list available providers",
+				Aliases:      []string{"p"},
+				Action:       providers,
+				BashComplete: complete(completeNone),
 				Flags: []cli.Flag{
 					&cli.BoolFlag{
 						Name:  "details",
@@ -517,23 +1485,132 @@ func main() {
 				},
 			},
 			{
-				Name:     "sync",
-				HelpName: "sync [provider list]",
-				Usage:    "synchronize remote repositories",
-				Aliases:  []string{"S"},
-				Action:   sync,
+				Name:         "sync",
+				HelpName:     "sync [provider list]",
+				Usage:        "synchronize remote repositories",
+				Aliases:      []string{"S"},
+				Action:       sync,
+				BashComplete: complete(completeProviders),
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "contents",
+						Usage: "synchronize Contents indices as well, required by the provides command",
+					},
+				},
 			},
 			{
-				Name:    "search",
-				Usage:   "search remote repositories for a package",
-				Aliases: []string{"s"},
-				Action:  search,
+				Name:         "search",
+				Usage:        "search remote repositories for a package",
+				Aliases:      []string{"s"},
+				Action:       search,
+				BashComplete: complete(completionPackageNames),
 				Flags: []cli.Flag{
 					&cli.BoolFlag{
 						Name:    "exact",
 						Aliases: []string{"e"},
 						Usage:   "perform an exact match of the package name provided",
 					},
+					&cli.BoolFlag{
+						Name:  "contains",
+						Usage: "match package names containing the value provided",
+					},
+					&cli.BoolFlag{
+						Name:  "glob",
+						Usage: "match package names against the glob pattern provided, e.g. 'google-chrome-*'",
+					},
+					&cli.BoolFlag{
+						Name:  "regex",
+						Usage: "match package names against the regular expression provided, e.g. '^(vs)?code$'",
+					},
+					&cli.IntFlag{
+						Name:  "limit",
+						Usage: "display at most this many results, 0 means unlimited",
+					},
+					&cli.BoolFlag{
+						Name:  "latest-only",
+						Usage: "display the newest version of each package per provider and distribution only",
+					},
+					&cli.BoolFlag{
+						Name:  "description",
+						Usage: "match all search terms against package names, sections and descriptions, ranked by relevance",
+					},
+					&cli.StringFlag{
+						Name:    "provider",
+						Usage:   "limit search results to a specific provider",
+						Aliases: []string{"p"},
+					},
+					&cli.StringFlag{
+						Name:    "distribution",
+						Usage:   "limit search results to a specific distribution (requires --provider)",
+						Aliases: []string{"dist", "d"},
+					},
+				},
+			},
+			{
+				Name:         "versions",
+				HelpName:     "versions <package>",
+				Usage:        "list all available versions of a package per provider and distribution",
+				Action:       versions,
+				BashComplete: complete(completionPackageNames),
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "provider",
+						Usage:   "limit versions to a specific provider",
+						Aliases: []string{"p"},
+					},
+					&cli.StringFlag{
+						Name:    "distribution",
+						Usage:   "limit versions to a specific distribution (requires --provider)",
+						Aliases: []string{"dist", "d"},
+					},
+				},
+			},
+			{
+				Name:         "show",
+				HelpName:     "show <package>",
+				Usage:        "show details of the package candidate that would be installed",
+				Action:       show,
+				BashComplete: complete(completionPackageNames),
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "provider",
+						Usage:   "limit candidates to a specific provider",
+						Aliases: []string{"p"},
+					},
+					&cli.StringFlag{
+						Name:    "distribution",
+						Usage:   "limit candidates to a specific distribution (requires --provider)",
+						Aliases: []string{"dist", "d"},
+					},
+				},
+			},
+			{
+				Name:         "depends",
+				HelpName:     "depends <package>",
+				Usage:        "display the dependency tree of a package",
+				Action:       depends,
+				BashComplete: complete(completionPackageNames),
+				Flags:        dependencyTreeFlags,
+			},
+			{
+				Name:         "rdepends",
+				HelpName:     "rdepends <package>",
+				Usage:        "display the reverse dependency tree of a package",
+				Action:       rdepends,
+				BashComplete: complete(completionPackageNames),
+				Flags:        dependencyTreeFlags,
+			},
+			{
+				Name:         "provides",
+				HelpName:     "provides <path or regex>",
+				Usage:        "search synced Contents indices for packages shipping a file",
+				Action:       provides,
+				BashComplete: complete(completeNone),
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "regex",
+						Usage: "match file paths against the regular expression provided instead of a substring",
+					},
 					&cli.StringFlag{
 						Name:    "provider",
 						Usage:   "limit search results to a specific provider",
@@ -547,10 +1624,30 @@ func main() {
 				},
 			},
 			{
-				Name:    "clean",
-				Usage:   "cleanup temporary xdeb context root path, optionally the repository lists as well",
-				Aliases: []string{"c"},
-				Action:  clean,
+				Name:         "policy",
+				HelpName:     "policy <package>",
+				Usage:        "explain which package candidate would be installed and why",
+				Action:       policy,
+				BashComplete: complete(completionPackageNames),
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "provider",
+						Usage:   "limit candidates to a specific provider",
+						Aliases: []string{"p"},
+					},
+					&cli.StringFlag{
+						Name:    "distribution",
+						Usage:   "limit candidates to a specific distribution (requires --provider)",
+						Aliases: []string{"dist", "d"},
+					},
+				},
+			},
+			{
+				Name:         "clean",
+				Usage:        "cleanup temporary xdeb context root path, optionally the repository lists and the download cache as well",
+				Aliases:      []string{"c"},
+				Action:       clean,
+				BashComplete: complete(completeNone),
 				Flags: []cli.Flag{
 					&cli.BoolFlag{
 						Name:    "lists",
@@ -558,8 +1655,20 @@ func main() {
 						Usage:   "cleanup repository lists as well",
 						Value:   false,
 					},
+					&cli.BoolFlag{
+						Name:  "cache",
+						Usage: "cleanup the download cache of DEB packages as well",
+						Value: false,
+					},
 				},
 			},
+			{
+				Name:         "completion",
+				HelpName:     "completion <shell>",
+				Usage:        fmt.Sprintf("print the completion script of a shell, any of %v", xdeb.COMPLETION_SHELLS),
+				Action:       completion,
+				BashComplete: complete(completeShells),
+			},
 		},
 	}
 
@@ -11,6 +11,22 @@ var ARCHITECTURE_MAP = map[string]string{
 	"386":   "i686",
 }
 
+var DEBIAN_ARCHITECTURE_MAP = map[string]string{
+	"amd64": "amd64",
+	"arm64": "arm64",
+	"386":   "i386",
+}
+
+func FindDebianArchitecture() (string, error) {
+	arch, ok := DEBIAN_ARCHITECTURE_MAP[runtime.GOARCH]
+
+	if !ok {
+		return "", fmt.Errorf("architecture '%s' not supported (yet)", runtime.GOARCH)
+	}
+
+	return arch, nil
+}
+
 func FindArchitecture() (string, error) {
 	arch, ok := ARCHITECTURE_MAP[runtime.GOARCH]
 
@@ -0,0 +1,336 @@
+package xdeb
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/adrg/xdg"
+	"gopkg.in/yaml.v2"
+)
+
+// references credentials only, so that providers can be printed safely
+type ProviderAuth struct {
+	Netrc       bool   `yaml:"netrc,omitempty" json:"netrc,omitempty"`
+	UsernameEnv string `yaml:"username-env,omitempty" json:"username-env,omitempty"`
+	PasswordEnv string `yaml:"password-env,omitempty" json:"password-env,omitempty"`
+	TokenEnv    string `yaml:"token-env,omitempty" json:"token-env,omitempty"`
+	Entry       string `yaml:"entry,omitempty" json:"entry,omitempty"`
+}
+
+// a token is sent as bearer token, username and password using basic auth
+type httpCredentials struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	Token    string `yaml:"token"`
+}
+
+type authConfig struct {
+	Credentials map[string]httpCredentials `yaml:"credentials"`
+}
+
+var errMissingCredentials = errors.New("could not find credentials")
+
+type hostAuth struct {
+	provider    string
+	auth        ProviderAuth
+	credentials *httpCredentials
+	err         error
+}
+
+var authMutex sync.Mutex
+
+// authentication of providers by scheme and host, e.g. https://example.com:8443
+var hostAuths = map[string]*hostAuth{}
+
+func AuthConfigPath() string {
+	return filepath.Join(xdg.ConfigHome, APPLICATION_NAME, "auth.yaml")
+}
+
+func netrcPath() string {
+	if path := os.Getenv("NETRC"); len(path) > 0 {
+		return path
+	}
+
+	return filepath.Join(xdg.Home, ".netrc")
+}
+
+// default ports are left out, so that URLs with and without them match
+func authKey(requestUrl *url.URL) string {
+	scheme := strings.ToLower(requestUrl.Scheme)
+	host := strings.ToLower(requestUrl.Hostname())
+	port := requestUrl.Port()
+
+	if len(port) > 0 && !(scheme == "https" && port == "443") && !(scheme == "http" && port == "80") {
+		host = fmt.Sprintf("%s:%s", host, port)
+	}
+
+	return fmt.Sprintf("%s://%s", scheme, host)
+}
+
+// returns the credentials of the machine, or the default ones
+func parseNetrc(data string, machine string) *httpCredentials {
+	var current *httpCredentials
+	var fallback *httpCredentials
+	var found *httpCredentials
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	macro := false
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// macro definitions end with an empty line
+		if macro {
+			macro = len(strings.TrimSpace(line)) > 0
+			continue
+		}
+
+		fields := strings.Fields(line)
+
+		for i := 0; i < len(fields); i++ {
+			next := ""
+
+			if i+1 < len(fields) {
+				next = fields[i+1]
+			}
+
+			switch fields[i] {
+			case "machine":
+				current = &httpCredentials{}
+
+				if next == machine && found == nil {
+					found = current
+				}
+
+				i++
+			case "default":
+				current = &httpCredentials{}
+				fallback = current
+			case "login":
+				if current != nil {
+					current.Username = next
+				}
+
+				i++
+			case "password":
+				if current != nil {
+					current.Password = next
+				}
+
+				i++
+			case "account":
+				i++
+			case "macdef":
+				macro = true
+				i = len(fields)
+			}
+		}
+	}
+
+	if found != nil {
+		return found
+	}
+
+	return fallback
+}
+
+func (auth *ProviderAuth) resolve(host string) (*httpCredentials, error) {
+	sources := 0
+
+	for _, set := range []bool{auth.Netrc, len(auth.UsernameEnv)+len(auth.PasswordEnv)+len(auth.TokenEnv) > 0, len(auth.Entry) > 0} {
+		if set {
+			sources++
+		}
+	}
+
+	if sources != 1 {
+		return nil, fmt.Errorf("exactly one of netrc, environment variables or an entry of %s must be given", AuthConfigPath())
+	}
+
+	if auth.Netrc {
+		data, err := os.ReadFile(netrcPath())
+
+		if err != nil {
+			return nil, fmt.Errorf("could not read netrc file: %s", err.Error())
+		}
+
+		credentials := parseNetrc(string(data), host)
+
+		if credentials == nil {
+			return nil, fmt.Errorf("no credentials for %s in %s", host, netrcPath())
+		}
+
+		return credentials, nil
+	}
+
+	if len(auth.Entry) > 0 {
+		data, err := os.ReadFile(AuthConfigPath())
+
+		if err != nil {
+			return nil, fmt.Errorf("could not read auth config: %s", err.Error())
+		}
+
+		config := &authConfig{}
+
+		if err = yaml.Unmarshal(data, config); err != nil {
+			return nil, fmt.Errorf("could not parse auth config: %s", err.Error())
+		}
+
+		credentials, ok := config.Credentials[auth.Entry]
+
+		if !ok {
+			return nil, fmt.Errorf("no entry '%s' in %s", auth.Entry, AuthConfigPath())
+		}
+
+		return &credentials, nil
+	}
+
+	credentials := &httpCredentials{}
+
+	for _, variable := range []struct {
+		name  string
+		value *string
+	}{{auth.UsernameEnv, &credentials.Username}, {auth.PasswordEnv, &credentials.Password}, {auth.TokenEnv, &credentials.Token}} {
+		if len(variable.name) == 0 {
+			continue
+		}
+
+		value, ok := os.LookupEnv(variable.name)
+
+		if !ok {
+			return nil, fmt.Errorf("environment variable %s is not set", variable.name)
+		}
+
+		*variable.value = value
+	}
+
+	return credentials, nil
+}
+
+// credentials are resolved on the first request to one of the hosts of a provider
+func registerProviderAuth(providers []PackageListsProvider) {
+	authMutex.Lock()
+	defer authMutex.Unlock()
+
+	for _, provider := range providers {
+		for _, mirrorUrl := range provider.MirrorUrls() {
+			parsedUrl, err := url.Parse(mirrorUrl)
+
+			if err != nil {
+				continue
+			}
+
+			// credentials given in URLs are sent by Go itself
+			if password, ok := parsedUrl.User.Password(); ok {
+				addLogSecret(password)
+			}
+
+			if provider.Auth != nil {
+				hostAuths[authKey(parsedUrl)] = &hostAuth{provider: provider.Name, auth: *provider.Auth}
+			}
+		}
+	}
+}
+
+func findCredentials(requestUrl *url.URL) (*httpCredentials, error) {
+	authMutex.Lock()
+	defer authMutex.Unlock()
+
+	auth, ok := hostAuths[authKey(requestUrl)]
+
+	if !ok {
+		return nil, nil
+	}
+
+	if auth.credentials == nil && auth.err == nil {
+		auth.credentials, auth.err = auth.auth.resolve(requestUrl.Hostname())
+
+		if auth.err != nil {
+			auth.err = fmt.Errorf("%w of provider %s: %w", errMissingCredentials, auth.provider, auth.err)
+		} else {
+			addLogSecret(auth.credentials.Password)
+			addLogSecret(auth.credentials.Token)
+		}
+	}
+
+	return auth.credentials, auth.err
+}
+
+// adds credentials to requests per host, so that redirects to other hosts do not receive them
+type authTransport struct {
+	transport http.RoundTripper
+}
+
+func (transport *authTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if len(request.Header.Get("Authorization")) > 0 {
+		return transport.transport.RoundTrip(request)
+	}
+
+	credentials, err := findCredentials(request.URL)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if credentials == nil {
+		return transport.transport.RoundTrip(request)
+	}
+
+	request = request.Clone(request.Context())
+
+	if len(credentials.Token) > 0 {
+		request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", credentials.Token))
+	} else {
+		request.SetBasicAuth(credentials.Username, credentials.Password)
+	}
+
+	return transport.transport.RoundTrip(request)
+}
+
+// hides passwords in URLs
+func RedactUrl(rawUrl string) string {
+	parsedUrl, err := url.Parse(rawUrl)
+
+	if err != nil {
+		return rawUrl
+	}
+
+	return parsedUrl.Redacted()
+}
+
+var providerAuthLoaded = false
+
+// installs and downloads happen without parsing the lists, which registers the providers otherwise
+func loadProviderAuth() error {
+	if providerAuthLoaded {
+		return nil
+	}
+
+	providerAuthLoaded = true
+
+	if _, err := LoadPackageLists(); err == nil {
+		return nil
+	}
+
+	config, err := ParseConfig()
+
+	if err != nil {
+		return err
+	}
+
+	providers, err := mergeProviders(nil, config.Providers)
+
+	if err != nil {
+		return err
+	}
+
+	registerProviderAuth(providers)
+	return nil
+}
@@ -0,0 +1,337 @@
+package xdeb
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/adrg/xdg"
+	"golang.org/x/exp/slices"
+)
+
+const DOWNLOAD_CACHE_DEFAULT_MAX_AGE_DAYS = 30
+const DOWNLOAD_CACHE_DEFAULT_MAX_SIZE_MIB = 2048
+
+// zero values fall back to the defaults, negative values turn the limits off
+type DownloadCacheConfig struct {
+	Disabled   bool  `yaml:"disabled"`
+	MaxAgeDays int   `yaml:"max-age-days"`
+	MaxSizeMib int64 `yaml:"max-size-mib"`
+}
+
+// DEB packages are cached by their SHA256 checksum, or by URL and ETag (or Last-Modified) if there is none
+type downloadCache struct {
+	path   string
+	config DownloadCacheConfig
+}
+
+func DownloadCachePath() string {
+	return filepath.Join(xdg.CacheHome, APPLICATION_NAME, "debs")
+}
+
+func CleanDownloadCache() error {
+	return os.RemoveAll(DownloadCachePath())
+}
+
+var downloadCachePruned = false
+
+func openDownloadCache() (*downloadCache, error) {
+	config, err := ParseConfig()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Cache.Disabled {
+		return nil, nil
+	}
+
+	if config.Cache.MaxAgeDays == 0 {
+		config.Cache.MaxAgeDays = DOWNLOAD_CACHE_DEFAULT_MAX_AGE_DAYS
+	}
+
+	if config.Cache.MaxSizeMib == 0 {
+		config.Cache.MaxSizeMib = DOWNLOAD_CACHE_DEFAULT_MAX_SIZE_MIB
+	}
+
+	cache := &downloadCache{path: DownloadCachePath(), config: config.Cache}
+
+	// limits may have been lowered since the cache was pruned last, once per command is enough
+	if !downloadCachePruned {
+		downloadCachePruned = true
+
+		if err = cache.prune(); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	return cache, nil
+}
+
+func isSha256(value string) bool {
+	checksum, err := hex.DecodeString(value)
+	return err == nil && len(checksum) == sha256.Size
+}
+
+// returns an empty key if the package cannot be cached, checksums end up in paths and are validated therefore
+func (cache *downloadCache) checksumKey(checksum string) string {
+	if !isSha256(checksum) {
+		return ""
+	}
+
+	return filepath.Join("sha256", fmt.Sprintf("%s.deb", strings.ToLower(checksum)))
+}
+
+// packages without checksum are looked up by the response of their download, which saves a separate request
+func (cache *downloadCache) responseKey(requestUrl string, resp *http.Response) string {
+	validator := resp.Header.Get("ETag")
+
+	if len(validator) == 0 || strings.HasPrefix(validator, "W/") {
+		validator = resp.Header.Get("Last-Modified")
+	}
+
+	if len(validator) == 0 {
+		return ""
+	}
+
+	checksum := sha256.Sum256([]byte(fmt.Sprintf("%s\n%s", requestUrl, validator)))
+	return filepath.Join("url", fmt.Sprintf("%s.deb", hex.EncodeToString(checksum[:])))
+}
+
+// copies a cached package to path, entries used are kept longer
+func (cache *downloadCache) fetch(key string, path string) bool {
+	entryPath := filepath.Join(cache.path, key)
+
+	if _, err := os.Stat(entryPath); err != nil {
+		return false
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return false
+	}
+
+	if err := os.Link(entryPath, path); err != nil && copyFile(entryPath, path) != nil {
+		return false
+	}
+
+	now := time.Now()
+	os.Chtimes(entryPath, now, now)
+
+	return true
+}
+
+func (cache *downloadCache) remove(key string) {
+	os.Remove(filepath.Join(cache.path, key))
+}
+
+// the cache may be shared between machines, entries are therefore written to unique temporary files first
+func (cache *downloadCache) store(key string, path string) error {
+	entryPath := filepath.Join(cache.path, key)
+
+	if err := os.MkdirAll(filepath.Dir(entryPath), os.ModePerm); err != nil {
+		return err
+	}
+
+	source, err := os.Open(path)
+
+	if err != nil {
+		return err
+	}
+
+	defer source.Close()
+	temporary, err := os.CreateTemp(filepath.Dir(entryPath), ".*.tmp")
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(temporary.Name())
+
+	if _, err = io.Copy(temporary, source); err != nil {
+		temporary.Close()
+		return err
+	}
+
+	if err = temporary.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(temporary.Name(), entryPath)
+}
+
+// removes entries not used within the age limit, then the least recently used ones exceeding the size limit
+func (cache *downloadCache) prune() error {
+	type cacheEntry struct {
+		path string
+		info fs.FileInfo
+	}
+
+	entries := []cacheEntry{}
+	maxAge := time.Duration(cache.config.MaxAgeDays) * 24 * time.Hour
+
+	err := filepath.WalkDir(cache.path, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		info, err := entry.Info()
+
+		if err != nil {
+			return nil
+		}
+
+		if cache.config.MaxAgeDays > 0 && time.Since(info.ModTime()) > maxAge {
+			return os.Remove(path)
+		}
+
+		// temporary files of other processes still being written
+		if strings.HasSuffix(path, ".tmp") {
+			return nil
+		}
+
+		entries = append(entries, cacheEntry{path: path, info: info})
+		return nil
+	})
+
+	if err != nil || cache.config.MaxSizeMib < 0 {
+		return err
+	}
+
+	sort.Slice(entries, func(i int, j int) bool {
+		return entries[i].info.ModTime().After(entries[j].info.ModTime())
+	})
+
+	size := int64(0)
+
+	for _, entry := range entries {
+		size += entry.info.Size()
+
+		if size > cache.config.MaxSizeMib*1024*1024 {
+			if err := os.Remove(entry.path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// URLs of APT packages start with the mirror they have been synced from, the other mirrors of the provider serve them as well
+func packageMirrorUrls(packageDefinition *XdebPackageDefinition) []string {
+	urls := []string{packageDefinition.Url}
+	lists, err := LoadPackageLists()
+
+	if err != nil {
+		return urls
+	}
+
+	index := slices.IndexFunc(lists.Providers, func(provider PackageListsProvider) bool {
+		return provider.Name == packageDefinition.Provider
+	})
+
+	if index < 0 || lists.Providers[index].Custom {
+		return urls
+	}
+
+	mirrorUrls := lists.Providers[index].MirrorUrls()
+
+	for _, mirrorUrl := range mirrorUrls {
+		filename, ok := strings.CutPrefix(packageDefinition.Url, fmt.Sprintf("%s/", mirrorUrl))
+
+		if !ok {
+			continue
+		}
+
+		for _, otherUrl := range mirrorUrls {
+			if otherUrl != mirrorUrl {
+				urls = append(urls, fmt.Sprintf("%s/%s", otherUrl, filename))
+			}
+		}
+
+		break
+	}
+
+	return urls
+}
+
+// looks the package up in the download cache first, verified downloads are added to it afterwards
+func downloadPackage(packageDefinition *XdebPackageDefinition, path string) (string, string, error) {
+	if source := localFilePath(packageDefinition.Url); len(source) > 0 {
+		return copyLocalPackage(source, path)
+	}
+
+	if err := loadProviderAuth(); err != nil {
+		return "", "", err
+	}
+
+	cache, err := openDownloadCache()
+
+	if err != nil {
+		return "", "", err
+	}
+
+	client := newProviderHttpClient(packageDefinition.Provider)
+	key := ""
+
+	if cache != nil {
+		key = cache.checksumKey(packageDefinition.Sha256)
+	}
+
+	if len(key) > 0 && cache.fetch(key, path) {
+		checksum, err := fileChecksum(path)
+
+		if err == nil && (len(packageDefinition.Sha256) == 0 || checksum == packageDefinition.Sha256) {
+			LogMessage("Using cached download: %s", filepath.Join(cache.path, key))
+			return path, checksum, nil
+		}
+
+		LogMessage("Cached download %s is corrupt, downloading again", filepath.Join(cache.path, key))
+		cache.remove(key)
+		os.Remove(path)
+	}
+
+	checksum := ""
+	cached := false
+
+	err = newMirrorList().pull(packageMirrorUrls(packageDefinition), func(url string) error {
+		var err error
+
+		_, checksum, err = downloadFile(client, path, url, func(resp *http.Response) bool {
+			if cache == nil || len(packageDefinition.Sha256) > 0 {
+				return false
+			}
+
+			key = cache.responseKey(url, resp)
+			cached = resp.StatusCode == http.StatusOK && len(key) > 0 && cache.fetch(key, path)
+			return cached
+		})
+
+		return err
+	})
+
+	if err != nil {
+		return "", "", err
+	}
+
+	if cached {
+		LogMessage("Using cached download: %s", filepath.Join(cache.path, key))
+		checksum, err = fileChecksum(path)
+		return path, checksum, err
+	}
+
+	if len(key) > 0 && (len(packageDefinition.Sha256) == 0 || checksum == packageDefinition.Sha256) {
+		if err := cache.store(key, path); err != nil {
+			LogMessage("Could not add %s to the download cache: %s", filepath.Base(path), err.Error())
+		}
+	}
+
+	return path, checksum, nil
+}
@@ -0,0 +1,109 @@
+package xdeb
+
+import (
+	"fmt"
+
+	"golang.org/x/exp/slices"
+)
+
+const COMPLETION_SHELL_BASH = "bash"
+const COMPLETION_SHELL_ZSH = "zsh"
+const COMPLETION_SHELL_FISH = "fish"
+
+var COMPLETION_SHELLS = []string{COMPLETION_SHELL_BASH, COMPLETION_SHELL_ZSH, COMPLETION_SHELL_FISH}
+
+// The scripts always pass the word being completed (even if empty), followed by the
+// --generate-bash-completion flag of urfave/cli. SHELL is overridden because urfave/cli
+// decides on the format of command suggestions by looking at it.
+const COMPLETION_SCRIPT_BASH = `# bash completion for xdeb-install, generated by 'xdeb-install completion bash'
+_xdeb_install_completion() {
+  local cur="${COMP_WORDS[COMP_CWORD]}"
+  local IFS=$'\n'
+  local candidates=($(SHELL=bash "${COMP_WORDS[@]:0:COMP_CWORD}" "${cur}" --generate-bash-completion 2>/dev/null))
+  COMPREPLY=($(compgen -W "${candidates[*]}" -- "${cur}"))
+}
+
+complete -o bashdefault -o default -F _xdeb_install_completion xdeb-install
+`
+
+const COMPLETION_SCRIPT_ZSH = `#compdef xdeb-install
+# zsh completion for xdeb-install, generated by 'xdeb-install completion zsh'
+_xdeb_install() {
+  local -a candidates
+  candidates=("${(@f)$(SHELL=zsh ${words[@]:0:$((CURRENT-1))} "${words[CURRENT]}" --generate-bash-completion 2>/dev/null)}")
+
+  if [[ -n "${candidates[1]}" ]]; then
+    _describe 'values' candidates
+  else
+    _files
+  fi
+}
+
+if [[ "${funcstack[1]}" = "_xdeb_install" || "${funcstack[1]}" = "_xdeb-install" ]]; then
+  _xdeb_install "$@"
+else
+  compdef _xdeb_install xdeb-install
+fi
+`
+
+const COMPLETION_SCRIPT_FISH = `# fish completion for xdeb-install, generated by 'xdeb-install completion fish'
+function __xdeb_install_complete
+    set -l tokens (commandline -opc)
+    env SHELL=fish $tokens (commandline -ct) --generate-bash-completion 2>/dev/null
+end
+
+complete -c xdeb-install -f -a '(__xdeb_install_complete)'
+complete -c xdeb-install -s f -l file -r -F
+complete -c xdeb-install -s t -l temp -r -F
+`
+
+// package names are limited as there may be hundreds of thousands, the largest name
+// is always part of the result for shells to compute the common prefix of all names
+const COMPLETION_PACKAGE_NAMES_LIMIT = 1000
+
+func CompletionScript(shell string) (string, error) {
+	switch shell {
+	case COMPLETION_SHELL_BASH:
+		return COMPLETION_SCRIPT_BASH, nil
+	case COMPLETION_SHELL_ZSH:
+		return COMPLETION_SCRIPT_ZSH, nil
+	case COMPLETION_SHELL_FISH:
+		return COMPLETION_SCRIPT_FISH, nil
+	}
+
+	return "", fmt.Errorf("shell '%s' not supported, use any of %v", shell, COMPLETION_SHELLS)
+}
+
+func PackageNames(path string, prefix string) ([]string, error) {
+	if index, err := openPackageIndex(path); err == nil {
+		defer index.Close()
+		return index.names(prefix, COMPLETION_PACKAGE_NAMES_LIMIT), nil
+	}
+
+	files, err := componentFiles(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+
+	for _, file := range files {
+		fileNames, err := streamPackageNames(file, prefix)
+
+		if err != nil {
+			return nil, err
+		}
+
+		names = append(names, fileNames...)
+	}
+
+	slices.Sort(names)
+	names = slices.Compact(names)
+
+	if len(names) > COMPLETION_PACKAGE_NAMES_LIMIT {
+		names = append(names[:COMPLETION_PACKAGE_NAMES_LIMIT-1], names[len(names)-1])
+	}
+
+	return names, nil
+}
@@ -0,0 +1,40 @@
+package xdeb
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/adrg/xdg"
+	"gopkg.in/yaml.v2"
+)
+
+type XdebInstallConfig struct {
+	Policy PolicyDefinition    `yaml:"policy"`
+	Cache  DownloadCacheConfig `yaml:"cache"`
+	Http   HttpConfig          `yaml:"http"`
+	// user-defined providers, in the format of lists.yaml
+	Providers []PackageListsProvider `yaml:"providers"`
+}
+
+func ConfigPath() string {
+	return filepath.Join(xdg.ConfigHome, APPLICATION_NAME, "config.yaml")
+}
+
+func ParseConfig() (*XdebInstallConfig, error) {
+	config := &XdebInstallConfig{}
+	data, err := os.ReadFile(ConfigPath())
+
+	if err != nil {
+		if os.IsNotExist(err) {
+			return config, nil
+		}
+
+		return nil, err
+	}
+
+	if err = yaml.Unmarshal(data, config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
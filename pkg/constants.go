@@ -13,3 +13,10 @@ const XDEB_INSTALL_REPOSITORIES_TAG = "v1.1.2"
 const XDEB_INSTALL_REPOSITORIES_URL = "https://raw.githubusercontent.com/xdeb-org/xdeb-install-repositories"
 
 const HTTP_REQUEST_HEADERS_TIMEOUT = 10 * time.Second
+const HTTP_DEFAULT_RETRIES = 3
+const HTTP_RETRY_BACKOFF = 500 * time.Millisecond
+const HTTP_RETRY_MAX_BACKOFF = 10 * time.Second
+
+const SYNC_DEFAULT_JOBS = 8
+
+const POLICY_DEFAULT_PRIORITY = 500
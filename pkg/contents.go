@@ -0,0 +1,333 @@
+package xdeb
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type ContentsMatch struct {
+	Provider     string   `yaml:"provider" json:"provider"`
+	Distribution string   `yaml:"distribution" json:"distribution"`
+	Component    string   `yaml:"component" json:"component"`
+	Path         string   `yaml:"path" json:"path"`
+	Packages     []string `yaml:"packages" json:"packages"`
+}
+
+func contentsFilePath(directory string, dist string, component string) string {
+	return filepath.Join(directory, dist, fmt.Sprintf("%s.contents", component))
+}
+
+// Contents lines look like "usr/bin/speedcrunch    math/speedcrunch", the file path itself may contain spaces
+func parseContentsLine(line string) (string, []string, bool) {
+	index := strings.LastIndexAny(line, " \t")
+
+	if index < 0 {
+		return "", nil, false
+	}
+
+	path := strings.TrimSpace(line[:index])
+	locations := strings.Split(line[index+1:], ",")
+
+	if len(path) == 0 || (path == "FILE" && locations[0] == "LOCATION") {
+		return "", nil, false
+	}
+
+	return path, locations, true
+}
+
+// whole-distribution Contents files qualify packages outside of main with their component, e.g. "contrib/utils/foo"
+func contentsPackages(locations []string, component string, filterComponent bool) []string {
+	packages := []string{}
+
+	for _, location := range locations {
+		parts := strings.Split(location, "/")
+
+		if filterComponent {
+			if component == "main" && len(parts) > 2 {
+				continue
+			}
+
+			if component != "main" && (len(parts) < 3 || parts[0] != component) {
+				continue
+			}
+		}
+
+		packages = append(packages, parts[len(parts)-1])
+	}
+
+	return packages
+}
+
+// returns nil if the file does not exist
+func getContentsFile(ctx context.Context, client *http.Client, requestUrl string, metadata *ComponentMetadata) (*http.Response, error) {
+	resp, err := conditionalGet(ctx, client, requestUrl, metadata)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusNotModified {
+		return resp, nil
+	}
+
+	resp.Body.Close()
+
+	if isFailedResponse(resp) {
+		return nil, newHttpStatusError(requestUrl, resp)
+	}
+
+	return nil, nil
+}
+
+// splits the Contents file into the components given in a single pass, each component is written by its own goroutine
+func writeContentsFiles(resp *http.Response, requestUrl string, filePaths map[string]string, filterComponent bool) error {
+	reader, err := gzip.NewReader(resp.Body)
+
+	if err != nil {
+		return err
+	}
+
+	defer reader.Close()
+
+	pipeWriters := map[string]*io.PipeWriter{}
+	writers := map[string]*bufio.Writer{}
+	results := make(chan error, len(filePaths))
+
+	for component, filePath := range filePaths {
+		pipeReader, pipeWriter := io.Pipe()
+		pipeWriters[component] = pipeWriter
+		writers[component] = bufio.NewWriter(pipeWriter)
+
+		go func(filePath string) {
+			_, err := writeStreamCompressed(filePath, pipeReader)
+
+			// unblocks writing to the pipe if the file could not be written
+			pipeReader.CloseWithError(err)
+			results <- err
+		}(filePath)
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for err == nil && scanner.Scan() {
+		path, locations, ok := parseContentsLine(scanner.Text())
+
+		if !ok {
+			continue
+		}
+
+		for component, writer := range writers {
+			packages := contentsPackages(locations, component, filterComponent)
+
+			if len(packages) == 0 {
+				continue
+			}
+
+			if _, err = fmt.Fprintf(writer, "%s\t%s\n", path, strings.Join(packages, ",")); err != nil {
+				break
+			}
+		}
+	}
+
+	if err == nil {
+		err = scanner.Err()
+	}
+
+	for component, writer := range writers {
+		if err == nil {
+			err = writer.Flush()
+		}
+
+		pipeWriters[component].CloseWithError(err)
+	}
+
+	for range filePaths {
+		if writeErr := <-results; err == nil {
+			err = writeErr
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	for _, filePath := range filePaths {
+		err := writeComponentMetadata(filePath, &ComponentMetadata{
+			Url:          requestUrl,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// returns whether the component provides a Contents file of its own
+func pullComponentContentsFile(ctx context.Context, client *http.Client, directory string, urlPrefix string, dist string, component string, architecture string) (bool, error) {
+	filePath := contentsFilePath(directory, dist, component)
+	requestUrl := fmt.Sprintf("%s/dists/%s/%s/Contents-%s.gz", urlPrefix, dist, component, architecture)
+	resp, err := getContentsFile(ctx, client, requestUrl, readComponentMetadata(filePath))
+
+	if err != nil || resp == nil {
+		return false, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return true, nil
+	}
+
+	LogMessage("Syncing contents %s/%s: %s", filepath.Base(directory), dist, component)
+	return true, writeContentsFiles(resp, requestUrl, map[string]string{component: filePath}, false)
+}
+
+// whole-distribution Contents files (e.g. of Ubuntu) are downloaded once for all components
+func pullDistributionContentsFile(ctx context.Context, client *http.Client, directory string, urlPrefix string, dist string, components []string, architecture string) error {
+	filePaths := map[string]string{}
+	var metadata *ComponentMetadata
+
+	// a conditional request is only sent if all components have been split from the same file
+	for index, component := range components {
+		filePaths[component] = contentsFilePath(directory, dist, component)
+		componentMetadata := readComponentMetadata(filePaths[component])
+
+		if index == 0 {
+			metadata = componentMetadata
+		} else if metadata != nil && (componentMetadata == nil || *componentMetadata != *metadata) {
+			metadata = nil
+		}
+	}
+
+	requestUrl := fmt.Sprintf("%s/dists/%s/Contents-%s.gz", urlPrefix, dist, architecture)
+	resp, err := getContentsFile(ctx, client, requestUrl, metadata)
+
+	if err != nil || resp == nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil
+	}
+
+	LogMessage("Syncing contents %s/%s: %s", filepath.Base(directory), dist, strings.Join(components, ", "))
+	return writeContentsFiles(resp, requestUrl, filePaths, true)
+}
+
+// Contents files are large, so they are only downloaded again if the server reports them as modified
+func pullContentsFiles(ctx context.Context, client *http.Client, directory string, urlPrefix string, dist string, components []string, architecture string) error {
+	missing := []string{}
+
+	for _, component := range components {
+		found, err := pullComponentContentsFile(ctx, client, directory, urlPrefix, dist, component, architecture)
+
+		if err != nil {
+			return err
+		}
+
+		if !found {
+			missing = append(missing, component)
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	return pullDistributionContentsFile(ctx, client, directory, urlPrefix, dist, missing, architecture)
+}
+
+func searchContentsFile(path string, matchPath func(path string) bool) ([]*ContentsMatch, error) {
+	file, err := openCompressedFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	distPath := filepath.Dir(path)
+	matches := []*ContentsMatch{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		filePath, packages, found := strings.Cut(scanner.Text(), "\t")
+
+		if !found || !matchPath(filePath) {
+			continue
+		}
+
+		matches = append(matches, &ContentsMatch{
+			Provider:     filepath.Base(filepath.Dir(distPath)),
+			Distribution: filepath.Base(distPath),
+			Component:    TrimPathExtension(filepath.Base(path), 2),
+			Path:         filePath,
+			Packages:     strings.Split(packages, ","),
+		})
+	}
+
+	return matches, scanner.Err()
+}
+
+func SearchContents(pattern string, regex bool, path string, provider string, distribution string) ([]*ContentsMatch, error) {
+	LogMessage("Looking for files matching %s (regex: %t) via provider %s and distribution %s ...", pattern, regex, provider, distribution)
+
+	matchPath := func(filePath string) bool {
+		return strings.Contains(filePath, pattern)
+	}
+
+	if regex {
+		expression, err := regexp.Compile(pattern)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression '%s': %s", pattern, err.Error())
+		}
+
+		matchPath = expression.MatchString
+	}
+
+	globbed, err := filepath.Glob(filepath.Join(path, provider, distribution, "*.contents.zst"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(globbed) == 0 {
+		return nil, fmt.Errorf("no contents indices present on the system, please sync repositories with --contents first")
+	}
+
+	sort.Strings(globbed)
+	matches := []*ContentsMatch{}
+
+	for _, match := range globbed {
+		fileMatches, err := searchContentsFile(match, matchPath)
+
+		if err != nil {
+			return nil, err
+		}
+
+		matches = append(matches, fileMatches...)
+	}
+
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("could not find any package providing '%s'", pattern)
+	}
+
+	return matches, nil
+}
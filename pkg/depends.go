@@ -0,0 +1,318 @@
+package xdeb
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+const DEPENDENCY_STATUS_VOID = "void"
+const DEPENDENCY_STATUS_XDEB = "xdeb"
+const DEPENDENCY_STATUS_MISSING = "missing"
+
+type DependencyAlternative struct {
+	Name       string `yaml:"name" json:"name"`
+	Constraint string `yaml:"constraint,omitempty" json:"constraint,omitempty"`
+}
+
+type DependencyNode struct {
+	Name         string                  `yaml:"name" json:"name"`
+	Alternatives []DependencyAlternative `yaml:"alternatives,omitempty" json:"alternatives,omitempty"`
+	Version      string                  `yaml:"version,omitempty" json:"version,omitempty"`
+	Status       string                  `yaml:"status" json:"status"`
+	Repeated     bool                    `yaml:"repeated,omitempty" json:"repeated,omitempty"`
+	Children     []*DependencyNode       `yaml:"children,omitempty" json:"children,omitempty"`
+}
+
+type dependencyResolver struct {
+	packages    map[string]*XdebPackageDefinition
+	state       *InstallState
+	expanded    map[string]bool
+	maxDepth    int
+	reverseDeps map[string][]string
+}
+
+// drops architecture restrictions and build profiles, "<" within version constraints like "(<< 2.0)" is kept
+func stripRestrictions(alternative string) string {
+	var builder strings.Builder
+	closing := rune(0)
+	inVersion := false
+
+	for _, character := range alternative {
+		switch {
+		case closing != 0:
+			if character == closing {
+				closing = 0
+			}
+		case inVersion:
+			builder.WriteRune(character)
+			inVersion = character != ')'
+		case character == '(':
+			builder.WriteRune(character)
+			inVersion = true
+		case character == '[':
+			closing = ']'
+		case character == '<':
+			closing = '>'
+		default:
+			builder.WriteRune(character)
+		}
+	}
+
+	return strings.TrimSpace(builder.String())
+}
+
+// parses Depends-like fields, e.g. "libc6 (>= 2.34), libqt5gui5 (>= 5.0.2) | libqt5gui5-gles, perl:any"
+func ParseDependencies(field string) [][]DependencyAlternative {
+	dependencies := [][]DependencyAlternative{}
+
+	for _, relation := range strings.Split(field, ",") {
+		alternatives := []DependencyAlternative{}
+
+		for _, alternative := range strings.Split(relation, "|") {
+			alternative = strings.TrimSpace(alternative)
+
+			alternative = stripRestrictions(alternative)
+
+			if len(alternative) == 0 {
+				continue
+			}
+
+			dependency := DependencyAlternative{Name: alternative}
+
+			if name, constraint, found := strings.Cut(alternative, "("); found {
+				dependency.Name = strings.TrimSpace(name)
+				dependency.Constraint = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(constraint), ")"))
+			}
+
+			dependency.Name, _, _ = strings.Cut(dependency.Name, ":")
+			alternatives = append(alternatives, dependency)
+		}
+
+		if len(alternatives) > 0 {
+			dependencies = append(dependencies, alternatives)
+		}
+	}
+
+	return dependencies
+}
+
+func newDependencyResolver(packageDefinitions []*XdebPackageDefinition, maxDepth int) (*dependencyResolver, error) {
+	state, err := LoadInstallState()
+
+	if err != nil {
+		return nil, err
+	}
+
+	resolver := &dependencyResolver{
+		packages:    map[string]*XdebPackageDefinition{},
+		state:       state,
+		expanded:    map[string]bool{},
+		maxDepth:    maxDepth,
+		reverseDeps: map[string][]string{},
+	}
+
+	for _, packageDefinition := range packageDefinitions {
+		existing, ok := resolver.packages[packageDefinition.Name]
+
+		if !ok || compareVersions(packageDefinition.Version, existing.Version) > 0 {
+			resolver.packages[packageDefinition.Name] = packageDefinition
+		}
+	}
+
+	for name, packageDefinition := range resolver.packages {
+		for _, alternatives := range packageDefinition.dependencies() {
+			for _, alternative := range alternatives {
+				resolver.reverseDeps[alternative.Name] = append(resolver.reverseDeps[alternative.Name], name)
+			}
+		}
+	}
+
+	return resolver, nil
+}
+
+func (packageDefinition *XdebPackageDefinition) dependencies() [][]DependencyAlternative {
+	return append(ParseDependencies(packageDefinition.PreDepends), ParseDependencies(packageDefinition.Depends)...)
+}
+
+func (resolver *dependencyResolver) status(name string) string {
+	if resolver.state.Record(name) != nil {
+		return DEPENDENCY_STATUS_XDEB
+	}
+
+	if _, ok := resolver.state.nativeVersion(name); ok {
+		return DEPENDENCY_STATUS_VOID
+	}
+
+	return DEPENDENCY_STATUS_MISSING
+}
+
+func (resolver *dependencyResolver) newNode(name string) *DependencyNode {
+	node := &DependencyNode{
+		Name:   name,
+		Status: resolver.status(name),
+	}
+
+	if packageDefinition, ok := resolver.packages[name]; ok {
+		node.Version = packageDefinition.Version
+	}
+
+	return node
+}
+
+func (resolver *dependencyResolver) dependsTree(node *DependencyNode, depth int) {
+	packageDefinition, ok := resolver.packages[node.Name]
+
+	if !ok || depth >= resolver.maxDepth {
+		return
+	}
+
+	dependencies := packageDefinition.dependencies()
+
+	if resolver.expanded[node.Name] {
+		node.Repeated = len(dependencies) > 0
+		return
+	}
+
+	resolver.expanded[node.Name] = true
+
+	for _, alternatives := range dependencies {
+		// any satisfied alternative satisfies the whole relation, the tree continues with it
+		chosen := alternatives[0].Name
+
+		for _, alternative := range alternatives {
+			if resolver.status(alternative.Name) != DEPENDENCY_STATUS_MISSING {
+				chosen = alternative.Name
+				break
+			}
+		}
+
+		child := resolver.newNode(chosen)
+		child.Alternatives = alternatives
+
+		resolver.dependsTree(child, depth+1)
+		node.Children = append(node.Children, child)
+	}
+}
+
+func (resolver *dependencyResolver) rdependsTree(node *DependencyNode, depth int) {
+	if depth >= resolver.maxDepth {
+		return
+	}
+
+	reverseDependencies := uniqueSorted(resolver.reverseDeps[node.Name])
+
+	if resolver.expanded[node.Name] {
+		node.Repeated = len(reverseDependencies) > 0
+		return
+	}
+
+	resolver.expanded[node.Name] = true
+
+	for _, name := range reverseDependencies {
+		child := resolver.newNode(name)
+		resolver.rdependsTree(child, depth+1)
+		node.Children = append(node.Children, child)
+	}
+}
+
+func BuildDependencyTree(root *XdebPackageDefinition, packageDefinitions []*XdebPackageDefinition, maxDepth int, reverse bool) (*DependencyNode, error) {
+	resolver, err := newDependencyResolver(packageDefinitions, maxDepth)
+
+	if err != nil {
+		return nil, err
+	}
+
+	resolver.packages[root.Name] = root
+	node := resolver.newNode(root.Name)
+	node.Version = root.Version
+
+	if reverse {
+		resolver.rdependsTree(node, 0)
+	} else {
+		resolver.dependsTree(node, 0)
+	}
+
+	return node, nil
+}
+
+func (node *DependencyNode) constraint() string {
+	constraints := []string{}
+
+	for _, alternative := range node.Alternatives {
+		if len(alternative.Constraint) > 0 {
+			constraints = append(constraints, fmt.Sprintf("%s (%s)", alternative.Name, alternative.Constraint))
+		} else {
+			constraints = append(constraints, alternative.Name)
+		}
+	}
+
+	return strings.Join(constraints, " | ")
+}
+
+func (node *DependencyNode) WritePlain(writer io.Writer, depth int) {
+	label := node.Name
+
+	if len(node.Alternatives) > 0 {
+		label = node.constraint()
+	} else if len(node.Version) > 0 {
+		label = fmt.Sprintf("%s %s", node.Name, node.Version)
+	}
+
+	suffix := ""
+
+	if node.Repeated {
+		suffix = " (see above)"
+	}
+
+	fmt.Fprintf(writer, "%s%s [%s]%s\n", strings.Repeat("  ", depth), label, node.Status, suffix)
+
+	for _, child := range node.Children {
+		child.WritePlain(writer, depth+1)
+	}
+}
+
+var DEPENDENCY_STATUS_COLORS = map[string]string{
+	DEPENDENCY_STATUS_VOID:    "darkgreen",
+	DEPENDENCY_STATUS_XDEB:    "blue",
+	DEPENDENCY_STATUS_MISSING: "red",
+}
+
+func (node *DependencyNode) writeDotNodes(writer io.Writer, seen map[string]bool) {
+	if !seen[node.Name] {
+		seen[node.Name] = true
+		fmt.Fprintf(writer, "  %q [color=%s, tooltip=%q];\n", node.Name, DEPENDENCY_STATUS_COLORS[node.Status], node.Status)
+	}
+
+	for _, child := range node.Children {
+		if len(child.Alternatives) > 0 {
+			fmt.Fprintf(writer, "  %q -> %q [label=%q];\n", node.Name, child.Name, child.constraint())
+		} else {
+			fmt.Fprintf(writer, "  %q -> %q;\n", node.Name, child.Name)
+		}
+
+		child.writeDotNodes(writer, seen)
+	}
+}
+
+func (node *DependencyNode) WriteDot(writer io.Writer) {
+	fmt.Fprintf(writer, "digraph %q {\n", node.Name)
+	fmt.Fprintln(writer, "  node [shape=box];")
+	node.writeDotNodes(writer, map[string]bool{})
+	fmt.Fprintln(writer, "}")
+}
+
+func uniqueSorted(values []string) []string {
+	unique := []string{}
+
+	for _, value := range values {
+		if !slices.Contains(unique, value) {
+			unique = append(unique, value)
+		}
+	}
+
+	slices.Sort(unique)
+	return unique
+}
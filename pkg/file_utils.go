@@ -3,10 +3,15 @@ package xdeb
 import (
 	"bufio"
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/klauspost/compress/zstd"
 )
@@ -74,16 +79,15 @@ func writeFile(path string, data []byte) (string, error) {
 		return "", err
 	}
 
-	file, err := os.Create(path)
+	// written next to the destination and renamed, so that readers never see a partially written file
+	temporaryPath := fmt.Sprintf("%s.tmp", path)
 
-	if err != nil {
+	if err := os.WriteFile(temporaryPath, data, 0644); err != nil {
+		os.Remove(temporaryPath)
 		return "", err
 	}
 
-	defer file.Close()
-	_, err = file.Write(data)
-
-	return path, err
+	return path, os.Rename(temporaryPath, path)
 }
 
 func writeFileCompressed(path string, data []byte) (string, error) {
@@ -103,40 +107,320 @@ func writeFileCompressed(path string, data []byte) (string, error) {
 	return writeFile(fmt.Sprintf("%s.zst", path), compressedData.Bytes())
 }
 
-func DownloadFile(path string, baseRequestUrl string, followRedirects bool, compress bool) (string, error) {
-	requestUrl := baseRequestUrl
+type compressedFile struct {
+	io.Reader
+	file    io.Closer
+	decoder *zstd.Decoder
+}
+
+func (file *compressedFile) Close() error {
+	file.decoder.Close()
+	return file.file.Close()
+}
+
+func openCompressedFile(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	decoder, err := zstd.NewReader(bufio.NewReader(file))
+
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &compressedFile{Reader: decoder, file: file, decoder: decoder}, nil
+}
+
+// streams large data (e.g. Contents indices) to disk without keeping it in memory
+func writeStreamCompressed(path string, reader io.Reader) (string, error) {
+	path = fmt.Sprintf("%s.zst", path)
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return "", err
+	}
+
+	temporaryPath := fmt.Sprintf("%s.tmp", path)
+	file, err := os.Create(temporaryPath)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer os.Remove(temporaryPath)
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	encoder, err := zstd.NewWriter(writer)
+
+	if err != nil {
+		return "", err
+	}
+
+	if _, err = io.Copy(encoder, reader); err != nil {
+		encoder.Close()
+		return "", err
+	}
+
+	if err = encoder.Close(); err != nil {
+		return "", err
+	}
+
+	if err = writer.Flush(); err != nil {
+		return "", err
+	}
+
+	if err = file.Close(); err != nil {
+		return "", err
+	}
+
+	return path, os.Rename(temporaryPath, path)
+}
+
+// partial downloads are kept as <path>.part along with the validator (ETag or Last-Modified) of the response
+func partialDownloadPaths(path string) (string, string) {
+	partPath := fmt.Sprintf("%s.part", path)
+	return partPath, fmt.Sprintf("%s.validator", partPath)
+}
+
+func isPartialDownload(path string) bool {
+	return strings.HasSuffix(path, ".part") || strings.HasSuffix(path, ".part.validator")
+}
+
+// removes a directory, but keeps partial downloads so that they can be resumed
+func removeAllExceptPartialDownloads(path string) error {
+	entries, err := os.ReadDir(path)
+
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if !isPartialDownload(entry.Name()) {
+			if err := os.RemoveAll(filepath.Join(path, entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func fileChecksum(path string) (string, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer file.Close()
+	hasher := sha256.New()
+
+	if _, err = io.Copy(hasher, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// e.g. "bytes 1024-2047/2048"
+func contentRangeStart(contentRange string) (int64, bool) {
+	rangeSpec, ok := strings.CutPrefix(contentRange, "bytes ")
+
+	if !ok {
+		return 0, false
+	}
+
+	start, _, ok := strings.Cut(rangeSpec, "-")
 
-	client := NewHttpClient()
-	resp, err := client.Get(requestUrl)
+	if !ok {
+		return 0, false
+	}
+
+	value, err := strconv.ParseInt(start, 10, 64)
+	return value, err == nil
+}
+
+// streams the file to disk and returns its SHA256 checksum, interrupted downloads are resumed via Range requests,
+// cached is passed the response before its body is read and may provide the file instead, e.g. from the download cache
+func downloadFile(client *http.Client, path string, requestUrl string, cached func(resp *http.Response) bool) (string, string, error) {
+	partPath, validatorPath := partialDownloadPaths(path)
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return "", "", err
+	}
+
+	request, err := http.NewRequest(http.MethodGet, requestUrl, nil)
 
 	if err != nil {
-		return "", fmt.Errorf("could not download file '%s'", requestUrl)
+		return "", "", err
 	}
 
-	if followRedirects {
-		requestUrl = resp.Request.URL.String()
-		resp, err = client.Get(requestUrl)
+	offset := int64(0)
 
-		if err != nil {
-			return "", fmt.Errorf("could not download file '%s'", requestUrl)
+	// If-Range makes the server send the whole file again if it has changed in the meantime
+	if info, err := os.Stat(partPath); err == nil && info.Size() > 0 {
+		if validator, err := os.ReadFile(validatorPath); err == nil && len(validator) > 0 {
+			offset = info.Size()
+			request.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+			request.Header.Set("If-Range", string(validator))
 		}
 	}
 
-	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("could not download file '%s'", requestUrl)
+	resp, err := client.Do(request)
+
+	if err != nil {
+		return "", "", fmt.Errorf("could not download file '%s': %w", RedactUrl(requestUrl), err)
 	}
 
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		os.Remove(partPath)
+		os.Remove(validatorPath)
+
+		return downloadFile(client, path, requestUrl, cached)
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return "", "", newHttpStatusError(requestUrl, resp)
+	}
+
+	// servers may ignore the offset requested, appending their range to the partial download would corrupt it
+	if resp.StatusCode == http.StatusPartialContent {
+		if start, ok := contentRangeStart(resp.Header.Get("Content-Range")); !ok || start != offset {
+			if offset == 0 {
+				return "", "", fmt.Errorf("could not download file '%s': unexpected range '%s'", RedactUrl(requestUrl), resp.Header.Get("Content-Range"))
+			}
+
+			LogMessage("Could not resume download of %s, downloading it again", RedactUrl(requestUrl))
+			os.Remove(partPath)
+			os.Remove(validatorPath)
+
+			return downloadFile(client, path, requestUrl, cached)
+		}
+	}
+
+	if cached != nil && cached(resp) {
+		os.Remove(partPath)
+		os.Remove(validatorPath)
+
+		return path, "", nil
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+
+	if resp.StatusCode == http.StatusPartialContent {
+		LogMessage("Resuming download of %s at %s", RedactUrl(requestUrl), FormatByteSize(offset))
+		flags = os.O_RDWR
+	} else {
+		offset = 0
+		validator := resp.Header.Get("ETag")
+
+		if len(validator) == 0 || strings.HasPrefix(validator, "W/") {
+			validator = resp.Header.Get("Last-Modified")
+		}
+
+		if len(validator) > 0 {
+			err = os.WriteFile(validatorPath, []byte(validator), 0644)
+		} else {
+			err = os.Remove(validatorPath)
+		}
+
+		if err != nil && !os.IsNotExist(err) {
+			return "", "", err
+		}
+	}
+
+	file, err := os.OpenFile(partPath, flags, 0644)
 
 	if err != nil {
+		return "", "", err
+	}
+
+	defer file.Close()
+	hasher := sha256.New()
+
+	// the partial download is hashed first, which moves the offset to its end
+	if resp.StatusCode == http.StatusPartialContent {
+		if _, err = io.Copy(hasher, file); err != nil {
+			return "", "", err
+		}
+	}
+
+	total := resp.ContentLength
+
+	if total > 0 {
+		total += offset
+	}
+
+	progress := newDownloadProgress(filepath.Base(path), offset, total).start()
+	defer progress.stop()
+
+	if _, err = io.Copy(io.MultiWriter(file, hasher), progress.reader(resp.Body)); err != nil {
+		return "", "", fmt.Errorf("could not download file '%s', it will be resumed next time: %w", RedactUrl(requestUrl), err)
+	}
+
+	if err = file.Close(); err != nil {
+		return "", "", err
+	}
+
+	if err = os.Rename(partPath, path); err != nil {
+		return "", "", err
+	}
+
+	os.Remove(validatorPath)
+	return path, hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+func DownloadFile(path string, requestUrl string, compress bool) (string, error) {
+	if err := loadProviderAuth(); err != nil {
 		return "", err
 	}
 
-	if compress {
-		return writeFileCompressed(path, body)
+	if !compress {
+		path, _, err := downloadFile(NewHttpClient(), path, requestUrl, nil)
+		return path, err
+	}
+
+	resp, err := NewHttpClient().Get(requestUrl)
+
+	if err != nil {
+		return "", fmt.Errorf("could not download file '%s': %w", RedactUrl(requestUrl), err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", newHttpStatusError(requestUrl, resp)
+	}
+
+	progress := newDownloadProgress(filepath.Base(path), 0, resp.ContentLength).start()
+	defer progress.stop()
+
+	return writeStreamCompressed(path, progress.reader(resp.Body))
+}
+
+func FormatByteSize(size int64) string {
+	const unit = 1024
+
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	divisor, exponent := int64(unit), 0
+
+	for n := size / unit; n >= unit; n /= unit {
+		divisor *= unit
+		exponent++
 	}
 
-	return writeFile(path, body)
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(divisor), "KMGTPE"[exponent])
 }
@@ -1,11 +1,124 @@
 package xdeb
 
-import "net/http"
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+var httpRetries = HTTP_DEFAULT_RETRIES
+
+func SetHttpRetries(retries int) error {
+	if retries < 0 {
+		return fmt.Errorf("number of retries must not be negative")
+	}
+
+	httpRetries = retries
+	return nil
+}
+
+// retries idempotent requests failing with network errors or server errors
+type retryTransport struct {
+	transport http.RoundTripper
+	retries   int
+}
+
+func isRetryableResponse(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+}
+
+// missing or rejected credentials fail, object stores like S3 answer 403 for missing files however if none have been sent
+func isFailedResponse(resp *http.Response) bool {
+	if resp.StatusCode == http.StatusForbidden {
+		return resp.Request != nil && len(resp.Request.Header.Get("Authorization")) > 0
+	}
+
+	return resp.StatusCode == http.StatusUnauthorized || isRetryableResponse(resp, nil)
+}
+
+// responses still failing after retrying, see isFailedResponse, or unexpected otherwise
+type httpStatusError struct {
+	url        string
+	status     string
+	statusCode int
+}
+
+func newHttpStatusError(requestUrl string, resp *http.Response) error {
+	return &httpStatusError{url: requestUrl, status: resp.Status, statusCode: resp.StatusCode}
+}
+
+func (err *httpStatusError) Error() string {
+	return fmt.Sprintf("could not download file '%s': %s", RedactUrl(err.url), err.status)
+}
+
+// exponential backoff with jitter, so that concurrent requests do not retry in lockstep
+func retryBackoff(attempt int) time.Duration {
+	backoff := HTTP_RETRY_BACKOFF << attempt
+
+	if backoff > HTTP_RETRY_MAX_BACKOFF || backoff <= 0 {
+		backoff = HTTP_RETRY_MAX_BACKOFF
+	}
+
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)))
+}
+
+func (transport *retryTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.Method != http.MethodGet && request.Method != http.MethodHead {
+		return transport.transport.RoundTrip(request)
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := transport.transport.RoundTrip(request)
+
+		if attempt >= transport.retries || !isRetryableResponse(resp, err) || request.Context().Err() != nil {
+			if progress := progressFromContext(request.Context()); progress != nil && err == nil {
+				resp.Body = progress.reader(resp.Body)
+			}
+
+			return resp, err
+		}
+
+		if err == nil {
+			resp.Body.Close()
+		}
+
+		backoff := retryBackoff(attempt)
+		LogMessage("Retrying %s in %s (%d/%d)", request.URL.Redacted(), backoff.Round(time.Millisecond), attempt+1, transport.retries)
+
+		select {
+		case <-request.Context().Done():
+			return nil, request.Context().Err()
+		case <-time.After(backoff):
+		}
+	}
+}
 
 func NewHttpClient() *http.Client {
+	return newProviderHttpClient("")
+}
+
+// applies the TLS settings of the provider, see SetHttpConfig, and the credentials of the hosts requested,
+// file:// URLs are read from disk
+func newProviderHttpClient(provider string) *http.Client {
+	transport := &http.Transport{
+		Proxy:                 httpProxy,
+		TLSClientConfig:       providerTlsConfig(provider),
+		ResponseHeaderTimeout: HTTP_REQUEST_HEADERS_TIMEOUT,
+	}
+
+	transport.RegisterProtocol("file", fileTransport)
+
 	return &http.Client{
-		Transport: &http.Transport{
-			ResponseHeaderTimeout: HTTP_REQUEST_HEADERS_TIMEOUT,
+		Transport: &authTransport{
+			transport: &retryTransport{
+				transport: transport,
+				retries:   httpRetries,
+			},
 		},
 	}
 }
@@ -0,0 +1,167 @@
+package xdeb
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"golang.org/x/exp/slices"
+	"golang.org/x/net/http/httpproxy"
+)
+
+type HttpTlsConfig struct {
+	CaBundles         []string `yaml:"ca-bundles,omitempty"`
+	ClientCertificate string   `yaml:"client-certificate,omitempty"`
+	ClientKey         string   `yaml:"client-key,omitempty"`
+}
+
+// the proxy overrides HTTP_PROXY and HTTPS_PROXY, hosts not to use a proxy for extend NO_PROXY,
+// TLS settings of providers extend the global ones
+type HttpConfig struct {
+	Proxy         string   `yaml:"proxy,omitempty"`
+	NoProxy       []string `yaml:"no-proxy,omitempty"`
+	HttpTlsConfig `yaml:",inline"`
+	Providers     map[string]HttpTlsConfig `yaml:"providers,omitempty"`
+}
+
+var httpProxy = http.ProxyFromEnvironment
+
+// TLS configurations by provider name, the empty name holds the global one
+var httpTlsConfigs = map[string]*tls.Config{}
+
+// hosts not to use a proxy for match themselves and their subdomains, "*" matches all hosts
+func buildProxy(config *HttpConfig) (func(*http.Request) (*url.URL, error), error) {
+	proxyConfig := httpproxy.FromEnvironment()
+
+	if len(config.Proxy) > 0 {
+		proxyUrl, err := url.Parse(config.Proxy)
+
+		if err != nil || len(proxyUrl.Host) == 0 {
+			return nil, fmt.Errorf("invalid proxy URL '%s'", config.Proxy)
+		}
+
+		proxyConfig.HTTPProxy = config.Proxy
+		proxyConfig.HTTPSProxy = config.Proxy
+	}
+
+	noProxy := config.NoProxy
+
+	if len(proxyConfig.NoProxy) > 0 {
+		noProxy = append([]string{proxyConfig.NoProxy}, noProxy...)
+	}
+
+	proxyConfig.NoProxy = strings.Join(noProxy, ",")
+	proxy := proxyConfig.ProxyFunc()
+
+	return func(request *http.Request) (*url.URL, error) {
+		return proxy(request.URL)
+	}, nil
+}
+
+// returns nil if the defaults of Go apply
+func buildTlsConfig(config HttpTlsConfig) (*tls.Config, error) {
+	if len(config.CaBundles) == 0 && len(config.ClientCertificate) == 0 && len(config.ClientKey) == 0 {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{}
+
+	if len(config.CaBundles) > 0 {
+		pool, err := x509.SystemCertPool()
+
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		for _, caBundle := range config.CaBundles {
+			data, err := os.ReadFile(caBundle)
+
+			if err != nil {
+				return nil, fmt.Errorf("could not read CA bundle '%s': %s", caBundle, err.Error())
+			}
+
+			if !pool.AppendCertsFromPEM(data) {
+				return nil, fmt.Errorf("no certificates found in CA bundle '%s'", caBundle)
+			}
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	if len(config.ClientKey) > 0 && len(config.ClientCertificate) == 0 {
+		return nil, fmt.Errorf("client key '%s' given without a client certificate", config.ClientKey)
+	}
+
+	if len(config.ClientCertificate) > 0 {
+		// the key may be part of the certificate file
+		clientKey := config.ClientKey
+
+		if len(clientKey) == 0 {
+			clientKey = config.ClientCertificate
+		}
+
+		certificate, err := tls.LoadX509KeyPair(config.ClientCertificate, clientKey)
+
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate '%s': %s", config.ClientCertificate, err.Error())
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	return tlsConfig, nil
+}
+
+func SetHttpConfig(config *HttpConfig) error {
+	proxy, err := buildProxy(config)
+
+	if err != nil {
+		return err
+	}
+
+	tlsConfigs := map[string]*tls.Config{}
+
+	if tlsConfigs[""], err = buildTlsConfig(config.HttpTlsConfig); err != nil {
+		return err
+	}
+
+	for provider, providerConfig := range config.Providers {
+		merged := HttpTlsConfig{
+			CaBundles:         append(slices.Clone(config.CaBundles), providerConfig.CaBundles...),
+			ClientCertificate: config.ClientCertificate,
+			ClientKey:         config.ClientKey,
+		}
+
+		if len(providerConfig.ClientCertificate) > 0 || len(providerConfig.ClientKey) > 0 {
+			merged.ClientCertificate = providerConfig.ClientCertificate
+			merged.ClientKey = providerConfig.ClientKey
+		}
+
+		if tlsConfigs[provider], err = buildTlsConfig(merged); err != nil {
+			return fmt.Errorf("provider %s: %w", provider, err)
+		}
+	}
+
+	httpProxy = proxy
+	httpTlsConfigs = tlsConfigs
+
+	return nil
+}
+
+func providerTlsConfig(provider string) *tls.Config {
+	tlsConfig, ok := httpTlsConfigs[provider]
+
+	if !ok {
+		tlsConfig = httpTlsConfigs[""]
+	}
+
+	if tlsConfig == nil {
+		return nil
+	}
+
+	return tlsConfig.Clone()
+}
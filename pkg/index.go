@@ -0,0 +1,612 @@
+package xdeb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"golang.org/x/exp/slices"
+)
+
+// Layout of the package index, all integers are little endian:
+//
+//	magic | record blocks | locations | blocks | entries (sorted by name) | entry offsets | footer
+//
+// Records are JSON encoded and compressed in blocks. Only the tables following the
+// record blocks are read into memory, record blocks are read on demand.
+const PACKAGE_INDEX_FILENAME = "index.bin"
+const PACKAGE_INDEX_MAGIC = "XDEBIDX\x01"
+const PACKAGE_INDEX_FOOTER_SIZE = 8*5 + 4 + 4
+const PACKAGE_INDEX_BLOCK_SIZE = 64 * 1024
+
+var errPackageIndexCorrupt = errors.New("package index is corrupt")
+
+type packageIndexLocation struct {
+	provider     string
+	distribution string
+	component    string
+}
+
+type packageIndexBlock struct {
+	offset uint64
+	length uint32
+}
+
+type packageIndexEntry struct {
+	name         string
+	location     uint16
+	block        uint32
+	recordOffset uint32
+	recordLength uint32
+}
+
+type packageIndex struct {
+	file      *os.File
+	decoder   *zstd.Decoder
+	locations []packageIndexLocation
+	blocks    []packageIndexBlock
+	entries   []byte
+	offsets   []byte
+	count     int
+
+	// full scans read records in block order, so caching a single block is enough
+	cachedBlock     int
+	cachedBlockData []byte
+}
+
+func PackageIndexPath(path string) string {
+	return filepath.Join(path, PACKAGE_INDEX_FILENAME)
+}
+
+func componentFiles(path string) ([]string, error) {
+	return filepath.Glob(filepath.Join(path, "*", "*", "*.yaml.zst"))
+}
+
+type packageIndexWriter struct {
+	writer  *bufio.Writer
+	encoder *zstd.Encoder
+	offset  uint64
+	block   []byte
+	blocks  []packageIndexBlock
+}
+
+func (indexWriter *packageIndexWriter) flushBlock() error {
+	if len(indexWriter.block) == 0 {
+		return nil
+	}
+
+	compressed := indexWriter.encoder.EncodeAll(indexWriter.block, nil)
+
+	if _, err := indexWriter.writer.Write(compressed); err != nil {
+		return err
+	}
+
+	indexWriter.blocks = append(indexWriter.blocks, packageIndexBlock{indexWriter.offset, uint32(len(compressed))})
+	indexWriter.offset += uint64(len(compressed))
+	indexWriter.block = indexWriter.block[:0]
+
+	return nil
+}
+
+func (indexWriter *packageIndexWriter) addRecord(record []byte) (uint32, uint32, error) {
+	if len(indexWriter.block)+len(record) > PACKAGE_INDEX_BLOCK_SIZE {
+		if err := indexWriter.flushBlock(); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	recordOffset := uint32(len(indexWriter.block))
+	indexWriter.block = append(indexWriter.block, record...)
+
+	return uint32(len(indexWriter.blocks)), recordOffset, nil
+}
+
+func appendIndexString(data []byte, value string) []byte {
+	data = binary.LittleEndian.AppendUint16(data, uint16(len(value)))
+	return append(data, value...)
+}
+
+func BuildPackageIndex(path string) error {
+	LogMessage("Building package index: %s", PackageIndexPath(path))
+
+	files, err := componentFiles(path)
+
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(files)
+
+	temporaryPath := fmt.Sprintf("%s.tmp", PackageIndexPath(path))
+	file, err := os.Create(temporaryPath)
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(temporaryPath)
+	defer file.Close()
+
+	encoder, err := zstd.NewWriter(nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer encoder.Close()
+
+	indexWriter := &packageIndexWriter{
+		writer:  bufio.NewWriter(file),
+		encoder: encoder,
+		offset:  uint64(len(PACKAGE_INDEX_MAGIC)),
+	}
+
+	if _, err = indexWriter.writer.WriteString(PACKAGE_INDEX_MAGIC); err != nil {
+		return err
+	}
+
+	locations := []packageIndexLocation{}
+	entries := []packageIndexEntry{}
+
+	for _, match := range files {
+		packageDefinitions, err := streamPackageDefinitions(match, "", false, func(name string) bool {
+			return true
+		})
+
+		if err != nil {
+			return err
+		}
+
+		distPath := filepath.Dir(match)
+		locations = append(locations, packageIndexLocation{
+			provider:     filepath.Base(filepath.Dir(distPath)),
+			distribution: filepath.Base(distPath),
+			component:    TrimPathExtension(filepath.Base(match), 2),
+		})
+
+		for _, packageDefinition := range packageDefinitions {
+			record, err := json.Marshal(packageDefinition)
+
+			if err != nil {
+				return err
+			}
+
+			block, recordOffset, err := indexWriter.addRecord(record)
+
+			if err != nil {
+				return err
+			}
+
+			entries = append(entries, packageIndexEntry{
+				name:         packageDefinition.Name,
+				location:     uint16(len(locations) - 1),
+				block:        block,
+				recordOffset: recordOffset,
+				recordLength: uint32(len(record)),
+			})
+		}
+	}
+
+	if err = indexWriter.flushBlock(); err != nil {
+		return err
+	}
+
+	sort.SliceStable(entries, func(i int, j int) bool {
+		return entries[i].name < entries[j].name
+	})
+
+	locationsOffset := indexWriter.offset
+	table := binary.LittleEndian.AppendUint32(nil, uint32(len(locations)))
+
+	for _, location := range locations {
+		table = appendIndexString(table, fmt.Sprintf("%s/%s/%s", location.provider, location.distribution, location.component))
+	}
+
+	blocksOffset := locationsOffset + uint64(len(table))
+	table = binary.LittleEndian.AppendUint32(table, uint32(len(indexWriter.blocks)))
+
+	for _, block := range indexWriter.blocks {
+		table = binary.LittleEndian.AppendUint64(table, block.offset)
+		table = binary.LittleEndian.AppendUint32(table, block.length)
+	}
+
+	entriesOffset := locationsOffset + uint64(len(table))
+	entriesStart := len(table)
+	entryOffsets := []byte{}
+
+	for _, entry := range entries {
+		entryOffsets = binary.LittleEndian.AppendUint32(entryOffsets, uint32(len(table)-entriesStart))
+		table = appendIndexString(table, entry.name)
+		table = binary.LittleEndian.AppendUint16(table, entry.location)
+		table = binary.LittleEndian.AppendUint32(table, entry.block)
+		table = binary.LittleEndian.AppendUint32(table, entry.recordOffset)
+		table = binary.LittleEndian.AppendUint32(table, entry.recordLength)
+	}
+
+	entriesLength := uint64(len(table) - entriesStart)
+	offsetsOffset := locationsOffset + uint64(len(table))
+	table = append(table, entryOffsets...)
+
+	// footer
+	for _, value := range []uint64{locationsOffset, blocksOffset, entriesOffset, entriesLength, offsetsOffset} {
+		table = binary.LittleEndian.AppendUint64(table, value)
+	}
+
+	table = binary.LittleEndian.AppendUint32(table, uint32(len(entries)))
+	table = append(table, PACKAGE_INDEX_MAGIC[:4]...)
+
+	if _, err = indexWriter.writer.Write(table); err != nil {
+		return err
+	}
+
+	if err = indexWriter.writer.Flush(); err != nil {
+		return err
+	}
+
+	if err = file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(temporaryPath, PackageIndexPath(path))
+}
+
+// the index is ignored as long as any component has been synced after it has been built
+func isPackageIndexCurrent(path string, indexInfo os.FileInfo) bool {
+	files, err := componentFiles(path)
+
+	if err != nil || len(files) == 0 {
+		return false
+	}
+
+	for _, file := range files {
+		info, err := os.Stat(file)
+
+		if err != nil || info.ModTime().After(indexInfo.ModTime()) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func openPackageIndex(path string) (*packageIndex, error) {
+	file, err := os.Open(PackageIndexPath(path))
+
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+
+	if err != nil || !isPackageIndexCurrent(path, info) {
+		file.Close()
+		return nil, fmt.Errorf("package index is outdated")
+	}
+
+	if info.Size() < int64(len(PACKAGE_INDEX_MAGIC)+PACKAGE_INDEX_FOOTER_SIZE) {
+		file.Close()
+		return nil, errPackageIndexCorrupt
+	}
+
+	index, err := readPackageIndex(file, info.Size())
+
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return index, nil
+}
+
+// a corrupt index is rebuilt, any other error leaves falling back to the component files to the caller
+func openOrRebuildPackageIndex(path string) (*packageIndex, error) {
+	index, err := openPackageIndex(path)
+
+	if !errors.Is(err, errPackageIndexCorrupt) {
+		return index, err
+	}
+
+	LogMessage("Package index is corrupt, rebuilding it")
+
+	if err = BuildPackageIndex(path); err != nil {
+		return nil, err
+	}
+
+	return openPackageIndex(path)
+}
+
+// all offsets and lengths are validated, so that a truncated or otherwise corrupt index is rebuilt instead of being read
+func readPackageIndex(file *os.File, size int64) (*packageIndex, error) {
+	footer := make([]byte, PACKAGE_INDEX_FOOTER_SIZE)
+
+	if _, err := file.ReadAt(footer, size-PACKAGE_INDEX_FOOTER_SIZE); err != nil {
+		return nil, err
+	}
+
+	if string(footer[PACKAGE_INDEX_FOOTER_SIZE-4:]) != PACKAGE_INDEX_MAGIC[:4] {
+		return nil, errPackageIndexCorrupt
+	}
+
+	tableEnd := uint64(size - PACKAGE_INDEX_FOOTER_SIZE)
+	locationsOffset := binary.LittleEndian.Uint64(footer[0:])
+	blocksOffset := binary.LittleEndian.Uint64(footer[8:])
+	entriesOffset := binary.LittleEndian.Uint64(footer[16:])
+	entriesLength := binary.LittleEndian.Uint64(footer[24:])
+	offsetsOffset := binary.LittleEndian.Uint64(footer[32:])
+	count := uint64(binary.LittleEndian.Uint32(footer[40:]))
+
+	// the tables are stored right after each other, followed by the footer
+	if locationsOffset < uint64(len(PACKAGE_INDEX_MAGIC)) || locationsOffset > blocksOffset || blocksOffset > entriesOffset ||
+		entriesOffset > offsetsOffset || offsetsOffset-entriesOffset != entriesLength || offsetsOffset > tableEnd ||
+		tableEnd-offsetsOffset != count*4 {
+		return nil, errPackageIndexCorrupt
+	}
+
+	table := make([]byte, tableEnd-locationsOffset)
+
+	if _, err := file.ReadAt(table, int64(locationsOffset)); err != nil {
+		return nil, err
+	}
+
+	decoder, err := zstd.NewReader(nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	index := &packageIndex{
+		file:        file,
+		decoder:     decoder,
+		entries:     table[entriesOffset-locationsOffset : offsetsOffset-locationsOffset],
+		offsets:     table[offsetsOffset-locationsOffset:],
+		count:       int(count),
+		cachedBlock: -1,
+	}
+
+	if err := index.readTables(table[:blocksOffset-locationsOffset], table[blocksOffset-locationsOffset:entriesOffset-locationsOffset], locationsOffset); err != nil {
+		decoder.Close()
+		return nil, err
+	}
+
+	return index, nil
+}
+
+func (index *packageIndex) readTables(locations []byte, blocks []byte, blocksEnd uint64) error {
+	if len(locations) < 4 || len(blocks) < 4 {
+		return errPackageIndexCorrupt
+	}
+
+	locationCount := binary.LittleEndian.Uint32(locations)
+	locations = locations[4:]
+
+	for i := uint32(0); i < locationCount; i++ {
+		if len(locations) < 2 || len(locations) < 2+int(binary.LittleEndian.Uint16(locations)) {
+			return errPackageIndexCorrupt
+		}
+
+		length := binary.LittleEndian.Uint16(locations)
+		parts := strings.SplitN(string(locations[2:2+length]), "/", 3)
+		locations = locations[2+length:]
+
+		if len(parts) != 3 {
+			return errPackageIndexCorrupt
+		}
+
+		index.locations = append(index.locations, packageIndexLocation{parts[0], parts[1], parts[2]})
+	}
+
+	blockCount := uint64(binary.LittleEndian.Uint32(blocks))
+
+	if uint64(len(blocks)) != 4+blockCount*12 {
+		return errPackageIndexCorrupt
+	}
+
+	for i := uint64(0); i < blockCount; i++ {
+		block := blocks[4+i*12:]
+		offset := binary.LittleEndian.Uint64(block)
+		length := binary.LittleEndian.Uint32(block[8:])
+
+		if offset < uint64(len(PACKAGE_INDEX_MAGIC)) || offset > blocksEnd || blocksEnd-offset < uint64(length) {
+			return errPackageIndexCorrupt
+		}
+
+		index.blocks = append(index.blocks, packageIndexBlock{offset, length})
+	}
+
+	for position := 0; position < index.count; position++ {
+		entryOffset := int(binary.LittleEndian.Uint32(index.offsets[position*4:]))
+
+		if entryOffset+2 > len(index.entries) {
+			return errPackageIndexCorrupt
+		}
+
+		nameLength := int(binary.LittleEndian.Uint16(index.entries[entryOffset:]))
+
+		if entryOffset+2+nameLength+14 > len(index.entries) {
+			return errPackageIndexCorrupt
+		}
+
+		if entry := index.entry(position); int(entry.location) >= len(index.locations) || int(entry.block) >= len(index.blocks) {
+			return errPackageIndexCorrupt
+		}
+	}
+
+	return nil
+}
+
+func (index *packageIndex) Close() error {
+	index.decoder.Close()
+	return index.file.Close()
+}
+
+func (index *packageIndex) entry(position int) packageIndexEntry {
+	data := index.entries[binary.LittleEndian.Uint32(index.offsets[position*4:]):]
+	nameLength := int(binary.LittleEndian.Uint16(data))
+	data = data[2:]
+
+	return packageIndexEntry{
+		name:         string(data[:nameLength]),
+		location:     binary.LittleEndian.Uint16(data[nameLength:]),
+		block:        binary.LittleEndian.Uint32(data[nameLength+2:]),
+		recordOffset: binary.LittleEndian.Uint32(data[nameLength+6:]),
+		recordLength: binary.LittleEndian.Uint32(data[nameLength+10:]),
+	}
+}
+
+func (index *packageIndex) entryName(position int) string {
+	data := index.entries[binary.LittleEndian.Uint32(index.offsets[position*4:]):]
+	nameLength := int(binary.LittleEndian.Uint16(data))
+	return string(data[2 : 2+nameLength])
+}
+
+func (index *packageIndex) readBlock(position int) ([]byte, error) {
+	if position == index.cachedBlock {
+		return index.cachedBlockData, nil
+	}
+
+	block := index.blocks[position]
+	compressed := make([]byte, block.length)
+
+	if _, err := index.file.ReadAt(compressed, int64(block.offset)); err != nil {
+		return nil, err
+	}
+
+	data, err := index.decoder.DecodeAll(compressed, nil)
+
+	if err != nil {
+		return nil, errPackageIndexCorrupt
+	}
+
+	index.cachedBlock = position
+	index.cachedBlockData = data
+
+	return data, nil
+}
+
+func (index *packageIndex) record(entry packageIndexEntry) (*XdebPackageDefinition, error) {
+	data, err := index.readBlock(int(entry.block))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if uint64(entry.recordOffset)+uint64(entry.recordLength) > uint64(len(data)) {
+		return nil, errPackageIndexCorrupt
+	}
+
+	packageDefinition := &XdebPackageDefinition{}
+
+	if err := json.Unmarshal(data[entry.recordOffset:entry.recordOffset+entry.recordLength], packageDefinition); err != nil {
+		return nil, errPackageIndexCorrupt
+	}
+
+	location := index.locations[entry.location]
+	packageDefinition.Provider = location.provider
+	packageDefinition.Distribution = location.distribution
+	packageDefinition.Component = location.component
+
+	return packageDefinition, nil
+}
+
+func (index *packageIndex) matchLocations(provider string, distribution string) []bool {
+	matches := make([]bool, len(index.locations))
+
+	for position, location := range index.locations {
+		matches[position] = (provider == "*" || provider == location.provider) &&
+			(distribution == "*" || distribution == location.distribution)
+	}
+
+	return matches
+}
+
+// a non-empty prefix narrows down the entries to look at via binary search, exact lookups stop after the entries of the name
+func (index *packageIndex) find(provider string, distribution string, prefix string, exact bool, matchName func(name string) bool) ([]*XdebPackageDefinition, error) {
+	locations := index.matchLocations(provider, distribution)
+
+	if !slices.Contains(locations, true) {
+		return nil, fmt.Errorf("no repositories present on the system, please sync repositories first")
+	}
+
+	start := sort.Search(index.count, func(position int) bool {
+		return index.entryName(position) >= prefix
+	})
+
+	entries := []packageIndexEntry{}
+
+	for position := start; position < index.count; position++ {
+		name := index.entryName(position)
+
+		if !strings.HasPrefix(name, prefix) || (exact && name != prefix) {
+			break
+		}
+
+		if !matchName(name) {
+			continue
+		}
+
+		if entry := index.entry(position); locations[entry.location] {
+			entries = append(entries, entry)
+		}
+	}
+
+	// read records in the order they are stored in
+	sort.SliceStable(entries, func(i int, j int) bool {
+		if entries[i].block != entries[j].block {
+			return entries[i].block < entries[j].block
+		}
+
+		return entries[i].recordOffset < entries[j].recordOffset
+	})
+
+	packageDefinitions := []*XdebPackageDefinition{}
+
+	for _, entry := range entries {
+		packageDefinition, err := index.record(entry)
+
+		if err != nil {
+			return nil, err
+		}
+
+		packageDefinitions = append(packageDefinitions, packageDefinition)
+	}
+
+	return packageDefinitions, nil
+}
+
+// package names starting with the prefix, without reading any records
+func (index *packageIndex) names(prefix string, limit int) []string {
+	start := sort.Search(index.count, func(position int) bool {
+		return index.entryName(position) >= prefix
+	})
+
+	end := sort.Search(index.count, func(position int) bool {
+		name := index.entryName(position)
+		return name > prefix && !strings.HasPrefix(name, prefix)
+	})
+
+	names := []string{}
+
+	for position := start; position < end; position++ {
+		name := index.entryName(position)
+
+		if len(names) > 0 && names[len(names)-1] == name {
+			continue
+		}
+
+		if limit > 0 && len(names) == limit-1 {
+			names = append(names, index.entryName(end-1))
+			break
+		}
+
+		names = append(names, name)
+	}
+
+	return names
+}
@@ -0,0 +1,147 @@
+package xdeb
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+func IsTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// candidates are grouped by package name and ordered by the policy, denied candidates are left out
+func selectableCandidates(policy *PolicyDefinition, packageDefinitions []*XdebPackageDefinition, provider string, distribution string) []*XdebPackageDefinition {
+	groups := map[string][]*XdebPackageDefinition{}
+	names := []string{}
+
+	for _, packageDefinition := range packageDefinitions {
+		if _, ok := groups[packageDefinition.Name]; !ok {
+			names = append(names, packageDefinition.Name)
+		}
+
+		groups[packageDefinition.Name] = append(groups[packageDefinition.Name], packageDefinition)
+	}
+
+	sort.Strings(names)
+	candidates := []*XdebPackageDefinition{}
+
+	for _, name := range names {
+		for _, candidate := range policy.EvaluatePackages(groups[name], provider, distribution) {
+			if !candidate.Denied {
+				candidates = append(candidates, candidate.Package)
+			}
+		}
+	}
+
+	return candidates
+}
+
+// parses selections like "1 3", "1,3" or "2-4" into zero-based indices
+func parseSelection(selection string, count int) ([]int, error) {
+	indices := []int{}
+	seen := map[int]bool{}
+
+	for _, field := range strings.FieldsFunc(selection, func(r rune) bool { return unicode.IsSpace(r) || r == ',' }) {
+		first, last, isRange := strings.Cut(field, "-")
+
+		if !isRange {
+			last = first
+		}
+
+		start, err := strconv.Atoi(first)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid selection '%s'", field)
+		}
+
+		end, err := strconv.Atoi(last)
+
+		if err != nil || start > end || start < 1 || end > count {
+			return nil, fmt.Errorf("invalid selection '%s', choose between 1 and %d", field, count)
+		}
+
+		for number := start; number <= end; number++ {
+			if !seen[number] {
+				seen[number] = true
+				indices = append(indices, number-1)
+			}
+		}
+	}
+
+	return indices, nil
+}
+
+func PickPackages(policy *PolicyDefinition, packageDefinitions []*XdebPackageDefinition, provider string, distribution string, input io.Reader, output io.Writer) ([]*XdebPackageDefinition, error) {
+	candidates := selectableCandidates(policy, packageDefinitions, provider, distribution)
+
+	if len(candidates) == 0 {
+		_, err := policy.SelectPackage(packageDefinitions, provider, distribution)
+		return nil, err
+	}
+
+	if len(candidates) == 1 {
+		return candidates, nil
+	}
+
+	for index, candidate := range candidates {
+		location := fmt.Sprintf("%s/%s/%s", candidate.Provider, candidate.Distribution, candidate.Component)
+
+		if len(candidate.Version) > 0 {
+			fmt.Fprintf(output, "%4d) %s %s (%s)\n", index+1, candidate.Name, candidate.Version, location)
+		} else {
+			fmt.Fprintf(output, "%4d) %s (%s)\n", index+1, candidate.Name, location)
+		}
+	}
+
+	reader := bufio.NewReader(input)
+
+	for {
+		fmt.Fprintf(output, "Packages to install (e.g. 1 2 4-6), leave empty to cancel: ")
+		line, err := reader.ReadString('\n')
+
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		selected, selectionErr := selectCandidates(candidates, line)
+
+		if selectionErr == nil {
+			return selected, nil
+		}
+
+		if err == io.EOF {
+			return nil, selectionErr
+		}
+
+		fmt.Fprintln(output, selectionErr.Error())
+	}
+}
+
+func selectCandidates(candidates []*XdebPackageDefinition, selection string) ([]*XdebPackageDefinition, error) {
+	indices, err := parseSelection(selection, len(candidates))
+
+	if err != nil {
+		return nil, err
+	}
+
+	selected := []*XdebPackageDefinition{}
+	names := map[string]bool{}
+
+	for _, index := range indices {
+		if names[candidates[index].Name] {
+			return nil, fmt.Errorf("more than one candidate of package '%s' selected, choose one", candidates[index].Name)
+		}
+
+		names[candidates[index].Name] = true
+		selected = append(selected, candidates[index])
+	}
+
+	return selected, nil
+}
@@ -0,0 +1,68 @@
+package xdeb
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// local APT mirrors are read through the file transport of Go, so that syncing them works like syncing remote ones
+var fileTransport = http.NewFileTransport(http.Dir("/"))
+
+// plain directories are turned into file:// URLs
+func localRepositoryUrl(repositoryUrl string) string {
+	if strings.HasPrefix(repositoryUrl, "file://") {
+		return strings.TrimRight(repositoryUrl, "/")
+	}
+
+	if parsedUrl, err := url.Parse(repositoryUrl); err == nil && len(parsedUrl.Scheme) > 0 {
+		return repositoryUrl
+	}
+
+	path, err := filepath.Abs(repositoryUrl)
+
+	if err != nil {
+		return repositoryUrl
+	}
+
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+// returns an empty path for URLs of other schemes
+func localFilePath(fileUrl string) string {
+	parsedUrl, err := url.Parse(fileUrl)
+
+	if err != nil || parsedUrl.Scheme != "file" {
+		return ""
+	}
+
+	return filepath.FromSlash(parsedUrl.Path)
+}
+
+// packages of local mirrors are copied instead of downloaded
+func copyLocalPackage(source string, path string) (string, string, error) {
+	if _, err := os.Stat(source); err != nil {
+		return "", "", fmt.Errorf("could not find file '%s'", source)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return "", "", err
+	}
+
+	LogMessage("Copying %s", source)
+
+	if err := copyFile(source, path); err != nil {
+		return "", "", err
+	}
+
+	checksum, err := fileChecksum(path)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	return path, checksum, nil
+}
@@ -1,8 +1,71 @@
 package xdeb
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+var logOutput io.Writer = os.Stdout
+
+var logMutex sync.Mutex
+
+// progress shown on terminals, redrawn below each log message
+var statusLine string
+
+// credentials resolved are never printed
+var logSecrets = []string{}
+
+// structured output must not be mixed with log messages
+func SetLogOutput(writer io.Writer) {
+	logOutput = writer
+}
 
 func LogMessage(format string, args ...any) {
 	message := fmt.Sprintf(format, args...)
-	fmt.Printf("%s %s\n", LOG_MESSAGE_PREFIX, message)
+
+	logMutex.Lock()
+	defer logMutex.Unlock()
+
+	for _, secret := range logSecrets {
+		message = strings.ReplaceAll(message, secret, "***")
+	}
+
+	if len(statusLine) > 0 {
+		fmt.Fprintf(logOutput, "\r\033[K%s %s\n%s", LOG_MESSAGE_PREFIX, message, statusLine)
+		return
+	}
+
+	fmt.Fprintf(logOutput, "%s %s\n", LOG_MESSAGE_PREFIX, message)
+}
+
+func setStatusLine(text string) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+
+	statusLine = fmt.Sprintf("%s %s", LOG_MESSAGE_PREFIX, text)
+	fmt.Fprintf(logOutput, "\r\033[K%s", statusLine)
+}
+
+func clearStatusLine() {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+
+	if len(statusLine) > 0 {
+		fmt.Fprint(logOutput, "\r\033[K")
+		statusLine = ""
+	}
+}
+
+func addLogSecret(secret string) {
+	if len(secret) == 0 {
+		return
+	}
+
+	logMutex.Lock()
+	defer logMutex.Unlock()
+
+	logSecrets = append(logSecrets, secret)
 }
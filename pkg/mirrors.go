@@ -0,0 +1,90 @@
+package xdeb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// mirrors failing once are skipped for the rest of a sync or download
+type mirrorList struct {
+	mutex sync.Mutex
+	bad   map[string]bool
+}
+
+func newMirrorList() *mirrorList {
+	return &mirrorList{bad: map[string]bool{}}
+}
+
+func (mirrors *mirrorList) isBad(url string) bool {
+	mirrors.mutex.Lock()
+	defer mirrors.mutex.Unlock()
+	return mirrors.bad[url]
+}
+
+func (mirrors *mirrorList) markBad(url string, err error) {
+	mirrors.mutex.Lock()
+	defer mirrors.mutex.Unlock()
+
+	if !mirrors.bad[url] {
+		LogMessage("Mirror %s failed, skipping it from now on: %s", RedactUrl(url), err.Error())
+		mirrors.bad[url] = true
+	}
+}
+
+// transport errors and server errors persisting after retries, as opposed to missing files,
+// rejected credentials or local errors, e.g. writing to disk
+func isMirrorFailure(err error) bool {
+	var urlError *url.Error
+	var netError net.Error
+	var statusError *httpStatusError
+
+	if errors.Is(err, errMissingCredentials) {
+		return false
+	}
+
+	if errors.As(err, &urlError) || errors.As(err, &netError) {
+		return true
+	}
+
+	return errors.As(err, &statusError) && (statusError.statusCode == http.StatusTooManyRequests || statusError.statusCode >= http.StatusInternalServerError)
+}
+
+// tries the URLs in order until pulling from one of them succeeds, only failing mirrors are skipped afterwards
+func (mirrors *mirrorList) pull(urls []string, pull func(url string) error) error {
+	errs := []error{}
+
+	for _, url := range urls {
+		if mirrors.isBad(url) {
+			continue
+		}
+
+		err := pull(url)
+
+		if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+
+		if isMirrorFailure(err) {
+			mirrors.markBad(url, err)
+		}
+
+		errs = append(errs, err)
+	}
+
+	if len(errs) == 0 {
+		redactedUrls := []string{}
+
+		for _, url := range urls {
+			redactedUrls = append(redactedUrls, RedactUrl(url))
+		}
+
+		return fmt.Errorf("all mirrors of %v have failed before", redactedUrls)
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,66 @@
+package xdeb
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"golang.org/x/exp/slices"
+	"gopkg.in/yaml.v2"
+)
+
+const OUTPUT_FORMAT_PLAIN = "plain"
+const OUTPUT_FORMAT_JSON = "json"
+const OUTPUT_FORMAT_YAML = "yaml"
+const OUTPUT_FORMAT_TABLE = "table"
+
+var OUTPUT_FORMATS = []string{OUTPUT_FORMAT_PLAIN, OUTPUT_FORMAT_JSON, OUTPUT_FORMAT_YAML, OUTPUT_FORMAT_TABLE}
+
+func IsStructuredOutput(format string) bool {
+	return slices.Contains([]string{OUTPUT_FORMAT_JSON, OUTPUT_FORMAT_YAML}, format)
+}
+
+func PrintStructured(format string, value any) error {
+	switch format {
+	case OUTPUT_FORMAT_JSON:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		return encoder.Encode(value)
+	case OUTPUT_FORMAT_YAML:
+		data, err := yaml.Marshal(value)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+
+	return fmt.Errorf("output format '%s' not supported, use any of %v", format, OUTPUT_FORMATS)
+}
+
+// empty cells are printed as "-" to keep the columns parseable
+func PrintTable(header []string, rows [][]string) error {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, strings.Join(header, "\t"))
+
+	for _, row := range rows {
+		cells := make([]string, len(row))
+
+		for index, cell := range row {
+			if len(cell) == 0 {
+				cell = "-"
+			}
+
+			cells[index] = strings.ReplaceAll(cell, "\t", " ")
+		}
+
+		fmt.Fprintln(writer, strings.Join(cells, "\t"))
+	}
+
+	return writer.Flush()
+}
@@ -0,0 +1,160 @@
+package xdeb
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+type PolicyPin struct {
+	Package      string `yaml:"package,omitempty" json:"package,omitempty"`
+	Provider     string `yaml:"provider,omitempty" json:"provider,omitempty"`
+	Distribution string `yaml:"distribution,omitempty" json:"distribution,omitempty"`
+	Component    string `yaml:"component,omitempty" json:"component,omitempty"`
+	Priority     int    `yaml:"priority" json:"priority"`
+}
+
+type PolicyDefinition struct {
+	Pins []PolicyPin `yaml:"pins"`
+}
+
+type PolicyCandidate struct {
+	Package  *XdebPackageDefinition `yaml:"package" json:"package"`
+	Priority int                    `yaml:"priority" json:"priority"`
+	Pin      *PolicyPin             `yaml:"pin,omitempty" json:"pin,omitempty"`
+	Denied   bool                   `yaml:"denied" json:"denied"`
+	Selected bool                   `yaml:"selected" json:"selected"`
+	Reason   string                 `yaml:"reason" json:"reason"`
+}
+
+func matchPolicyField(pattern string, value string) bool {
+	if len(pattern) == 0 || pattern == "*" {
+		return true
+	}
+
+	matched, err := filepath.Match(pattern, value)
+	return err == nil && matched
+}
+
+func (pin *PolicyPin) matches(packageDefinition *XdebPackageDefinition) bool {
+	return matchPolicyField(pin.Package, packageDefinition.Name) &&
+		matchPolicyField(pin.Provider, packageDefinition.Provider) &&
+		matchPolicyField(pin.Distribution, packageDefinition.Distribution) &&
+		matchPolicyField(pin.Component, packageDefinition.Component)
+}
+
+func (pin *PolicyPin) String() string {
+	fields := []string{pin.Package, pin.Provider, pin.Distribution, pin.Component}
+
+	for index := range fields {
+		if len(fields[index]) == 0 {
+			fields[index] = "*"
+		}
+	}
+
+	return fmt.Sprintf("%s @ %s/%s/%s", fields[0], fields[1], fields[2], fields[3])
+}
+
+// the first matching pin wins, just like APT preferences do
+func (policy *PolicyDefinition) findPin(packageDefinition *XdebPackageDefinition) *PolicyPin {
+	for index := range policy.Pins {
+		if policy.Pins[index].matches(packageDefinition) {
+			return &policy.Pins[index]
+		}
+	}
+
+	return nil
+}
+
+// negative priorities only apply as long as the user did not explicitly ask for the provider (and distribution)
+func isExplicitlyRequested(pin *PolicyPin, packageDefinition *XdebPackageDefinition, provider string, distribution string) bool {
+	if provider != packageDefinition.Provider {
+		return false
+	}
+
+	return len(pin.Distribution) == 0 || pin.Distribution == "*" || distribution == packageDefinition.Distribution
+}
+
+func (policy *PolicyDefinition) EvaluatePackages(packageDefinitions []*XdebPackageDefinition, provider string, distribution string) []*PolicyCandidate {
+	candidates := []*PolicyCandidate{}
+
+	for _, packageDefinition := range packageDefinitions {
+		candidate := &PolicyCandidate{
+			Package:  packageDefinition,
+			Priority: POLICY_DEFAULT_PRIORITY,
+			Pin:      policy.findPin(packageDefinition),
+		}
+
+		if candidate.Pin != nil {
+			candidate.Priority = candidate.Pin.Priority
+			candidate.Denied = candidate.Priority < 0 && !isExplicitlyRequested(candidate.Pin, packageDefinition, provider, distribution)
+		}
+
+		candidates = append(candidates, candidate)
+	}
+
+	sort.SliceStable(candidates, func(i int, j int) bool {
+		if candidates[i].Denied != candidates[j].Denied {
+			return !candidates[i].Denied
+		}
+
+		if candidates[i].Priority != candidates[j].Priority {
+			return candidates[i].Priority > candidates[j].Priority
+		}
+
+		return compareVersions(candidates[i].Package.Version, candidates[j].Package.Version) > 0
+	})
+
+	explainCandidates(candidates)
+	return candidates
+}
+
+func explainCandidates(candidates []*PolicyCandidate) {
+	if len(candidates) == 0 {
+		return
+	}
+
+	selected := candidates[0]
+
+	for _, candidate := range candidates {
+		if candidate.Denied {
+			candidate.Reason = fmt.Sprintf("denied by pin %s (priority %d)", candidate.Pin, candidate.Priority)
+			continue
+		}
+
+		if candidate == selected {
+			candidate.Selected = true
+			candidate.Reason = "highest priority and version"
+			continue
+		}
+
+		if candidate.Priority < selected.Priority {
+			candidate.Reason = fmt.Sprintf("lower priority (%d < %d)", candidate.Priority, selected.Priority)
+			continue
+		}
+
+		if compareVersions(candidate.Package.Version, selected.Package.Version) < 0 {
+			candidate.Reason = fmt.Sprintf("lower version (%s < %s)", candidate.Package.Version, selected.Package.Version)
+			continue
+		}
+
+		candidate.Reason = "same priority and version as the selected candidate, found later"
+	}
+}
+
+func (policy *PolicyDefinition) SelectPackage(packageDefinitions []*XdebPackageDefinition, provider string, distribution string) (*XdebPackageDefinition, error) {
+	candidates := policy.EvaluatePackages(packageDefinitions, provider, distribution)
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no package candidates to select from")
+	}
+
+	if candidates[0].Denied {
+		return nil, fmt.Errorf(
+			"all candidates for package '%s' are denied by the policy in %s, use --provider and --distribution to override",
+			candidates[0].Package.Name, ConfigPath(),
+		)
+	}
+
+	return candidates[0].Package, nil
+}
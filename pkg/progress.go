@@ -0,0 +1,168 @@
+package xdeb
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const PROGRESS_MODE_TERMINAL = "terminal"
+const PROGRESS_MODE_LOG = "log"
+const PROGRESS_MODE_NONE = "none"
+
+const PROGRESS_TERMINAL_INTERVAL = 200 * time.Millisecond
+const PROGRESS_LOG_INTERVAL = 5 * time.Second
+
+var progressMode = PROGRESS_MODE_LOG
+
+// terminals get a status line updated in place, anything else periodic log messages
+func SetProgressMode(mode string) {
+	progressMode = mode
+}
+
+// tracks either a single download or all components of a sync
+type transferProgress struct {
+	name       string
+	offset     int64
+	total      int64
+	components int64
+	completed  atomic.Int64
+	bytes      atomic.Int64
+	started    time.Time
+	done       chan struct{}
+	stopped    chan struct{}
+}
+
+type progressContextKey struct{}
+
+type progressReader struct {
+	io.ReadCloser
+	progress *transferProgress
+}
+
+func (reader *progressReader) Read(buffer []byte) (int, error) {
+	count, err := reader.ReadCloser.Read(buffer)
+	reader.progress.bytes.Add(int64(count))
+
+	return count, err
+}
+
+// total is the size of the whole file, offset the size of a partial download resumed
+func newDownloadProgress(name string, offset int64, total int64) *transferProgress {
+	return &transferProgress{name: name, offset: offset, total: total, started: time.Now()}
+}
+
+func newSyncProgress(components int) *transferProgress {
+	return &transferProgress{components: int64(components), started: time.Now()}
+}
+
+// the bytes of all responses to requests made with the context returned are counted
+func withProgress(ctx context.Context, progress *transferProgress) context.Context {
+	return context.WithValue(ctx, progressContextKey{}, progress)
+}
+
+func progressFromContext(ctx context.Context) *transferProgress {
+	progress, _ := ctx.Value(progressContextKey{}).(*transferProgress)
+	return progress
+}
+
+func (progress *transferProgress) reader(body io.ReadCloser) io.ReadCloser {
+	return &progressReader{ReadCloser: body, progress: progress}
+}
+
+func (progress *transferProgress) completeComponent() {
+	progress.completed.Add(1)
+}
+
+func formatDuration(duration time.Duration) string {
+	return duration.Round(time.Second).String()
+}
+
+func (progress *transferProgress) String() string {
+	bytes := progress.bytes.Load()
+	elapsed := time.Since(progress.started)
+	rate := int64(float64(bytes) / elapsed.Seconds())
+	parts := []string{}
+
+	if progress.components > 0 {
+		parts = append(parts, fmt.Sprintf("%d/%d components", progress.completed.Load(), progress.components))
+		parts = append(parts, FormatByteSize(bytes))
+	} else if progress.total > 0 {
+		current := progress.offset + bytes
+		parts = append(parts, fmt.Sprintf(
+			"%s / %s (%d%%)",
+			FormatByteSize(current), FormatByteSize(progress.total), current*100/progress.total,
+		))
+	} else {
+		parts = append(parts, FormatByteSize(progress.offset+bytes))
+	}
+
+	parts = append(parts, fmt.Sprintf("%s/s", FormatByteSize(rate)))
+
+	if progress.total > 0 && rate > 0 {
+		remaining := progress.total - progress.offset - bytes
+		parts = append(parts, fmt.Sprintf("ETA %s", formatDuration(time.Duration(remaining/rate)*time.Second)))
+	}
+
+	if progress.components > 0 {
+		return fmt.Sprintf("Syncing repositories: %s", strings.Join(parts, ", "))
+	}
+
+	return fmt.Sprintf("Downloading %s: %s", progress.name, strings.Join(parts, ", "))
+}
+
+func (progress *transferProgress) report() {
+	if progressMode == PROGRESS_MODE_TERMINAL {
+		setStatusLine(progress.String())
+	} else {
+		LogMessage("%s", progress.String())
+	}
+}
+
+func (progress *transferProgress) start() *transferProgress {
+	if progressMode == PROGRESS_MODE_NONE {
+		return progress
+	}
+
+	interval := PROGRESS_LOG_INTERVAL
+
+	if progressMode == PROGRESS_MODE_TERMINAL {
+		interval = PROGRESS_TERMINAL_INTERVAL
+	}
+
+	progress.done = make(chan struct{})
+	progress.stopped = make(chan struct{})
+
+	go func() {
+		defer close(progress.stopped)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-progress.done:
+				return
+			case <-ticker.C:
+				progress.report()
+			}
+		}
+	}()
+
+	return progress
+}
+
+func (progress *transferProgress) stop() {
+	if progress.done == nil {
+		return
+	}
+
+	close(progress.done)
+	<-progress.stopped
+	progress.done = nil
+
+	clearStatusLine()
+}
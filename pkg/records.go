@@ -0,0 +1,103 @@
+package xdeb
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/adrg/xdg"
+	"gopkg.in/yaml.v2"
+)
+
+type XdebInstallRecord struct {
+	Name         string    `yaml:"name" json:"name"`
+	Version      string    `yaml:"version,omitempty" json:"version,omitempty"`
+	XbpsPackage  string    `yaml:"xbps-package,omitempty" json:"xbps-package,omitempty"`
+	Provider     string    `yaml:"provider,omitempty" json:"provider,omitempty"`
+	Distribution string    `yaml:"distribution,omitempty" json:"distribution,omitempty"`
+	Component    string    `yaml:"component,omitempty" json:"component,omitempty"`
+	Url          string    `yaml:"url,omitempty" json:"url,omitempty"`
+	Sha256       string    `yaml:"sha256,omitempty" json:"sha256,omitempty"`
+	InstalledAt  time.Time `yaml:"installed-at" json:"installed-at"`
+}
+
+type XdebInstallRecords struct {
+	Packages []*XdebInstallRecord `yaml:"packages"`
+}
+
+func InstallRecordsPath() string {
+	return filepath.Join(xdg.DataHome, APPLICATION_NAME, "installed.yaml")
+}
+
+func ParseInstallRecords() (*XdebInstallRecords, error) {
+	records := &XdebInstallRecords{}
+	data, err := os.ReadFile(InstallRecordsPath())
+
+	if err != nil {
+		if os.IsNotExist(err) {
+			return records, nil
+		}
+
+		return nil, err
+	}
+
+	if err = yaml.Unmarshal(data, records); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+func (records *XdebInstallRecords) Find(name string) *XdebInstallRecord {
+	for _, record := range records.Packages {
+		if record.Name == name {
+			return record
+		}
+	}
+
+	return nil
+}
+
+func (records *XdebInstallRecords) add(record *XdebInstallRecord) {
+	for index := range records.Packages {
+		if records.Packages[index].Name == record.Name {
+			records.Packages[index] = record
+			return
+		}
+	}
+
+	records.Packages = append(records.Packages, record)
+}
+
+func (records *XdebInstallRecords) save() error {
+	data, err := yaml.Marshal(records)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = writeFile(InstallRecordsPath(), data)
+	return err
+}
+
+func recordInstalledPackage(packageDefinition *XdebPackageDefinition, xbpsPackage string) error {
+	records, err := ParseInstallRecords()
+
+	if err != nil {
+		return err
+	}
+
+	records.add(&XdebInstallRecord{
+		Name:         packageDefinition.Name,
+		Version:      packageDefinition.Version,
+		XbpsPackage:  xbpsPackage,
+		Provider:     packageDefinition.Provider,
+		Distribution: packageDefinition.Distribution,
+		Component:    packageDefinition.Component,
+		Url:          packageDefinition.Url,
+		Sha256:       packageDefinition.Sha256,
+		InstalledAt:  time.Now().UTC(),
+	})
+
+	return records.save()
+}
@@ -0,0 +1,140 @@
+package xdeb
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const PACKAGE_MATCH_EXACT = "exact"
+const PACKAGE_MATCH_PREFIX = "prefix"
+const PACKAGE_MATCH_CONTAINS = "contains"
+const PACKAGE_MATCH_GLOB = "glob"
+const PACKAGE_MATCH_REGEX = "regex"
+
+var PACKAGE_MATCH_MODES = []string{PACKAGE_MATCH_EXACT, PACKAGE_MATCH_PREFIX, PACKAGE_MATCH_CONTAINS, PACKAGE_MATCH_GLOB, PACKAGE_MATCH_REGEX}
+
+func newPackageMatcher(pattern string, matchMode string) (func(name string) bool, error) {
+	switch matchMode {
+	case PACKAGE_MATCH_EXACT:
+		return func(name string) bool {
+			return name == pattern
+		}, nil
+	case PACKAGE_MATCH_PREFIX:
+		return func(name string) bool {
+			return strings.HasPrefix(name, pattern)
+		}, nil
+	case PACKAGE_MATCH_CONTAINS:
+		return func(name string) bool {
+			return strings.Contains(name, pattern)
+		}, nil
+	case PACKAGE_MATCH_GLOB:
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob pattern '%s': %s", pattern, err.Error())
+		}
+
+		return func(name string) bool {
+			matched, _ := path.Match(pattern, name)
+			return matched
+		}, nil
+	case PACKAGE_MATCH_REGEX:
+		expression, err := regexp.Compile(pattern)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression '%s': %s", pattern, err.Error())
+		}
+
+		return expression.MatchString, nil
+	}
+
+	return nil, fmt.Errorf("match mode '%s' not supported, use any of %v", matchMode, PACKAGE_MATCH_MODES)
+}
+
+type scoredPackageDefinition struct {
+	packageDefinition *XdebPackageDefinition
+	score             int
+}
+
+// every term has to match the name, section or description, matches within the name weigh most
+func descriptionScore(packageDefinition *XdebPackageDefinition, terms []string) int {
+	name := strings.ToLower(packageDefinition.Name)
+	section := strings.ToLower(packageDefinition.Section)
+	summary, description, _ := strings.Cut(strings.ToLower(packageDefinition.Description), "\n")
+	score := 0
+
+	for _, term := range terms {
+		termScore := 0
+
+		if name == term {
+			termScore += 20
+		} else if strings.Contains(name, term) {
+			termScore += 10
+		}
+
+		if strings.Contains(summary, term) {
+			termScore += 5
+		}
+
+		if strings.Contains(section, term) {
+			termScore += 3
+		}
+
+		termScore += strings.Count(description, term)
+
+		if termScore == 0 {
+			return 0
+		}
+
+		score += termScore
+	}
+
+	return score
+}
+
+func SearchDescriptions(terms []string, path string, provider string, distribution string) ([]*XdebPackageDefinition, error) {
+	LogMessage("Looking for packages matching %v via provider %s and distribution %s ...", terms, provider, distribution)
+
+	for index := range terms {
+		terms[index] = strings.ToLower(terms[index])
+	}
+
+	packageDefinitions, err := LoadPackageDefinitions(path, provider, distribution)
+
+	if err != nil {
+		return nil, err
+	}
+
+	scored := []scoredPackageDefinition{}
+
+	for _, packageDefinition := range packageDefinitions {
+		if score := descriptionScore(packageDefinition, terms); score > 0 {
+			scored = append(scored, scoredPackageDefinition{packageDefinition, score})
+		}
+	}
+
+	if len(scored) == 0 {
+		return nil, fmt.Errorf("could not find any package matching %v", terms)
+	}
+
+	sort.SliceStable(scored, func(i int, j int) bool {
+		if scored[i].score != scored[j].score {
+			return scored[i].score > scored[j].score
+		}
+
+		if scored[i].packageDefinition.Name != scored[j].packageDefinition.Name {
+			return scored[i].packageDefinition.Name < scored[j].packageDefinition.Name
+		}
+
+		return compareVersions(scored[i].packageDefinition.Version, scored[j].packageDefinition.Version) > 0
+	})
+
+	results := []*XdebPackageDefinition{}
+
+	for _, entry := range scored {
+		results = append(results, entry.packageDefinition)
+	}
+
+	return results, nil
+}
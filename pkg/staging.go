@@ -0,0 +1,192 @@
+package xdeb
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/exp/slices"
+	"golang.org/x/sys/unix"
+)
+
+// Providers are synced into a staging copy of their directory, which replaces the
+// provider directory only after all of its components have been synced successfully.
+// The staging directory is a sibling of the repository path, so that it is neither
+// picked up as a provider nor located on a different file system.
+func SyncStagingPath(path string) string {
+	return filepath.Join(filepath.Dir(path), fmt.Sprintf(".%s.staging", filepath.Base(path)))
+}
+
+func copyFile(source string, destination string) error {
+	in, err := os.Open(source)
+
+	if err != nil {
+		return err
+	}
+
+	defer in.Close()
+	out, err := os.Create(destination)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
+
+// files are hard linked if possible, which is safe as files are only ever replaced, never written in place
+func linkTree(source string, destination string) error {
+	return filepath.WalkDir(source, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(source, path)
+
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(destination, relativePath)
+
+		if entry.IsDir() {
+			return os.MkdirAll(target, os.ModePerm)
+		}
+
+		if err = os.Link(path, target); err != nil {
+			return copyFile(path, target)
+		}
+
+		return nil
+	})
+}
+
+func stageProviderDirectory(path string, provider string) (string, error) {
+	directory := filepath.Join(path, provider)
+	staging := filepath.Join(SyncStagingPath(path), provider)
+	previous := fmt.Sprintf("%s.previous", staging)
+
+	// an earlier sync has been interrupted while swapping the directories
+	if _, err := os.Stat(directory); os.IsNotExist(err) {
+		if _, err = os.Stat(previous); err == nil {
+			if err = os.Rename(previous, directory); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	if err := os.RemoveAll(staging); err != nil {
+		return "", err
+	}
+
+	if err := os.RemoveAll(previous); err != nil {
+		return "", err
+	}
+
+	if _, err := os.Stat(directory); os.IsNotExist(err) {
+		return staging, os.MkdirAll(staging, os.ModePerm)
+	}
+
+	return staging, linkTree(directory, staging)
+}
+
+// removes distributions and components no longer synced for the provider, so that they are dropped from the package index,
+// returns whether anything has been removed
+func pruneProviderDirectory(staging string, provider PackageListsProvider) (bool, error) {
+	distributions, err := os.ReadDir(staging)
+
+	if err != nil {
+		return false, err
+	}
+
+	pruned := false
+
+	for _, distribution := range distributions {
+		distributionPath := filepath.Join(staging, distribution.Name())
+
+		if !distribution.IsDir() {
+			continue
+		}
+
+		if !slices.Contains(provider.Distributions, distribution.Name()) {
+			if err := os.RemoveAll(distributionPath); err != nil {
+				return pruned, err
+			}
+
+			pruned = true
+			continue
+		}
+
+		files, err := os.ReadDir(distributionPath)
+
+		if err != nil {
+			return pruned, err
+		}
+
+		for _, file := range files {
+			// e.g. main.yaml.zst, main.meta.yaml and main.contents.zst
+			if slices.ContainsFunc(provider.Components, func(component string) bool {
+				return strings.HasPrefix(file.Name(), fmt.Sprintf("%s.", component))
+			}) {
+				continue
+			}
+
+			if err := os.RemoveAll(filepath.Join(distributionPath, file.Name())); err != nil {
+				return pruned, err
+			}
+
+			pruned = true
+		}
+	}
+
+	return pruned, nil
+}
+
+// the staging directory is swapped with the provider directory, so that the latter never goes missing
+func commitProviderDirectory(path string, provider string, staging string) error {
+	directory := filepath.Join(path, provider)
+	previous := fmt.Sprintf("%s.previous", staging)
+
+	if err := os.MkdirAll(path, os.ModePerm); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(directory); os.IsNotExist(err) {
+		return os.Rename(staging, directory)
+	}
+
+	// swaps both directories atomically, fails with ENOSYS or EINVAL if the kernel or file system doesn't support it
+	err := unix.Renameat2(unix.AT_FDCWD, staging, unix.AT_FDCWD, directory, unix.RENAME_EXCHANGE)
+
+	if err == nil {
+		return os.RemoveAll(staging)
+	}
+
+	if !errors.Is(err, unix.ENOSYS) && !errors.Is(err, unix.EINVAL) {
+		return &os.LinkError{Op: "renameat2", Old: staging, New: directory, Err: err}
+	}
+
+	// an interrupted swap is completed by the next sync, see stageProviderDirectory
+	if err = os.Rename(directory, previous); err != nil {
+		return err
+	}
+
+	if err = os.Rename(staging, directory); err != nil {
+		if rollbackErr := os.Rename(previous, directory); rollbackErr != nil {
+			return fmt.Errorf("%w, could not restore provider directory from '%s': %v", err, previous, rollbackErr)
+		}
+
+		return err
+	}
+
+	return os.RemoveAll(previous)
+}
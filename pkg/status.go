@@ -0,0 +1,109 @@
+package xdeb
+
+import (
+	"fmt"
+	"strings"
+)
+
+const INSTALL_STATUS_NOT_INSTALLED = "not-installed"
+const INSTALL_STATUS_NATIVE = "native"
+const INSTALL_STATUS_XDEB = "xdeb"
+const INSTALL_STATUS_UPGRADABLE = "upgradable"
+
+type XdebInstallStatus struct {
+	Status  string `yaml:"status" json:"status"`
+	Version string `yaml:"version,omitempty" json:"version,omitempty"`
+}
+
+type XdebSearchResult struct {
+	XdebPackageDefinition `yaml:",inline"`
+	Status                *XdebInstallStatus `yaml:"status" json:"status"`
+}
+
+// cross-references the XBPS package database with the install records of xdeb-install
+type InstallState struct {
+	installed map[string]string
+	records   *XdebInstallRecords
+}
+
+func LoadInstallState() (*InstallState, error) {
+	installed, err := InstalledXbpsPackages()
+
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := ParseInstallRecords()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &InstallState{installed: installed, records: records}, nil
+}
+
+// records of packages removed via xbps-remove in the meantime are ignored, unless XBPS is not available at all
+func (state *InstallState) Record(name string) *XdebInstallRecord {
+	record := state.records.Find(name)
+
+	if record == nil {
+		return nil
+	}
+
+	if _, ok := state.installed[record.XbpsPackage]; ok || len(state.installed) == 0 || len(record.XbpsPackage) == 0 {
+		return record
+	}
+
+	return nil
+}
+
+func (state *InstallState) nativeVersion(name string) (string, bool) {
+	pkgver, ok := state.installed[name]
+	return strings.TrimPrefix(pkgver, fmt.Sprintf("%s-", name)), ok
+}
+
+func (state *InstallState) Status(packageDefinition *XdebPackageDefinition) *XdebInstallStatus {
+	if record := state.Record(packageDefinition.Name); record != nil {
+		if len(record.Version) > 0 && compareVersions(packageDefinition.Version, record.Version) > 0 {
+			return &XdebInstallStatus{Status: INSTALL_STATUS_UPGRADABLE, Version: record.Version}
+		}
+
+		return &XdebInstallStatus{Status: INSTALL_STATUS_XDEB, Version: record.Version}
+	}
+
+	if version, ok := state.nativeVersion(packageDefinition.Name); ok {
+		return &XdebInstallStatus{Status: INSTALL_STATUS_NATIVE, Version: version}
+	}
+
+	return &XdebInstallStatus{Status: INSTALL_STATUS_NOT_INSTALLED}
+}
+
+func (state *InstallState) SearchResults(packageDefinitions []*XdebPackageDefinition) []*XdebSearchResult {
+	results := []*XdebSearchResult{}
+
+	for _, packageDefinition := range packageDefinitions {
+		results = append(results, &XdebSearchResult{
+			XdebPackageDefinition: *packageDefinition,
+			Status:                state.Status(packageDefinition),
+		})
+	}
+
+	return results
+}
+
+func (status *XdebInstallStatus) String() string {
+	switch status.Status {
+	case INSTALL_STATUS_NATIVE:
+		return fmt.Sprintf("installed via xbps (%s)", status.Version)
+	case INSTALL_STATUS_XDEB:
+		if len(status.Version) == 0 {
+			return "installed via xdeb-install"
+		}
+
+		return fmt.Sprintf("installed via xdeb-install (%s)", status.Version)
+	case INSTALL_STATUS_UPGRADABLE:
+		return fmt.Sprintf("upgradable from %s (installed via xdeb-install)", status.Version)
+	}
+
+	return "not installed"
+}
@@ -0,0 +1,272 @@
+package xdeb
+
+import (
+	"bufio"
+	"errors"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Component files are read line by line instead of unmarshalling them as a whole:
+//
+//	sorted: true
+//	xdeb:
+//	- name: foo
+//	  version: "1.0"
+//	  ...
+//
+// Each package definition is only unmarshalled if its name matches, quoting, multi-line
+// scalars and the order of keys are left to yaml.v2. Sorted files (written by sync) are
+// not read any further once no more names can match the prefix. Exact lookups stop right
+// after the last definition of the package, the best candidate cannot be determined any
+// earlier as the candidate selection policy weighs all versions found.
+const COMPONENT_FILE_MAX_LINE_LENGTH = 16 * 1024 * 1024
+
+var errUnsupportedComponentFile = errors.New("unsupported component file layout")
+
+type componentFileReader struct {
+	prefix             string
+	exact              bool
+	matchName          func(name string) bool
+	sorted             bool
+	namesOnly          bool
+	names              []string
+	item               []string
+	itemName           string
+	packageDefinitions []*XdebPackageDefinition
+}
+
+func parseComponentFileScalar(value string) string {
+	value = strings.TrimSpace(value)
+
+	if strings.HasPrefix(value, "'") || strings.HasPrefix(value, "\"") {
+		var unquoted string
+
+		if err := yaml.Unmarshal([]byte(value), &unquoted); err == nil {
+			return unquoted
+		}
+	}
+
+	return value
+}
+
+// returns false as soon as none of the remaining package definitions can match anymore
+func (reader *componentFileReader) flush() (bool, error) {
+	item, name := reader.item, reader.itemName
+	reader.item, reader.itemName = nil, ""
+
+	if len(item) == 0 {
+		return true, nil
+	}
+
+	if len(name) > 0 {
+		if reader.sorted && len(reader.prefix) > 0 && name > reader.prefix && (reader.exact || !strings.HasPrefix(name, reader.prefix)) {
+			return false, nil
+		}
+
+		if !reader.matchName(name) {
+			return true, nil
+		}
+
+		if reader.namesOnly {
+			reader.names = append(reader.names, name)
+			return true, nil
+		}
+	}
+
+	packageDefinitions := []*XdebPackageDefinition{}
+
+	if err := yaml.Unmarshal([]byte(strings.Join(item, "\n")), &packageDefinitions); err != nil {
+		return false, err
+	}
+
+	// the name could not be determined up front in every case
+	for _, packageDefinition := range packageDefinitions {
+		if reader.matchName(packageDefinition.Name) {
+			reader.names = append(reader.names, packageDefinition.Name)
+			reader.packageDefinitions = append(reader.packageDefinitions, packageDefinition)
+		}
+	}
+
+	return true, nil
+}
+
+func (reader *componentFileReader) read(scanner *bufio.Scanner) error {
+	inList := false
+	itemIndent := ""
+	indentFound := false
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimLeft(line, " ")
+
+		if len(trimmed) == 0 || strings.HasPrefix(trimmed, "#") {
+			if len(reader.item) > 0 {
+				reader.item = append(reader.item, line)
+			}
+
+			continue
+		}
+
+		indent := line[:len(line)-len(trimmed)]
+		isItem := trimmed == "-" || strings.HasPrefix(trimmed, "- ")
+
+		// top-level keys
+		if len(indent) == 0 && !isItem {
+			if _, err := reader.flush(); err != nil {
+				return err
+			}
+
+			key, value, _ := strings.Cut(line, ":")
+			value = strings.TrimSpace(value)
+			inList = false
+
+			switch key {
+			case "sorted":
+				reader.sorted = value == "true"
+			case "xdeb":
+				if len(value) > 0 && value != "[]" && value != "null" && value != "~" {
+					return errUnsupportedComponentFile
+				}
+
+				inList = true
+			}
+
+			continue
+		}
+
+		if !inList {
+			continue
+		}
+
+		if isItem && !indentFound {
+			itemIndent = indent
+			indentFound = true
+		}
+
+		if isItem && indent == itemIndent {
+			more, err := reader.flush()
+
+			if err != nil || !more {
+				return err
+			}
+
+			reader.item = []string{line}
+
+			if field := strings.TrimPrefix(trimmed, "- "); strings.HasPrefix(field, "name:") {
+				reader.itemName = parseComponentFileScalar(strings.TrimPrefix(field, "name:"))
+			}
+
+			continue
+		}
+
+		if len(reader.item) == 0 {
+			return errUnsupportedComponentFile
+		}
+
+		reader.item = append(reader.item, line)
+
+		if len(indent) == len(itemIndent)+2 && strings.HasPrefix(trimmed, "name:") {
+			reader.itemName = parseComponentFileScalar(strings.TrimPrefix(trimmed, "name:"))
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	_, err := reader.flush()
+	return err
+}
+
+func readComponentFile(path string, reader *componentFileReader) error {
+	file, err := openCompressedFile(path)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), COMPONENT_FILE_MAX_LINE_LENGTH)
+
+	return reader.read(scanner)
+}
+
+// with exact set, the prefix is the name of the package looked up
+func streamPackageDefinitions(path string, prefix string, exact bool, matchName func(name string) bool) ([]*XdebPackageDefinition, error) {
+	reader := &componentFileReader{
+		prefix:    prefix,
+		exact:     exact,
+		matchName: matchName,
+	}
+
+	err := readComponentFile(path, reader)
+
+	if errors.Is(err, errUnsupportedComponentFile) {
+		return parseFilteredYamlDefinition(path, matchName)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return reader.packageDefinitions, nil
+}
+
+// package definitions are only unmarshalled if their names cannot be determined up front
+func streamPackageNames(path string, prefix string) ([]string, error) {
+	matchName := func(name string) bool {
+		return strings.HasPrefix(name, prefix)
+	}
+
+	reader := &componentFileReader{
+		prefix:    prefix,
+		matchName: matchName,
+		namesOnly: true,
+	}
+
+	err := readComponentFile(path, reader)
+
+	if errors.Is(err, errUnsupportedComponentFile) {
+		packageDefinitions, err := parseFilteredYamlDefinition(path, matchName)
+
+		if err != nil {
+			return nil, err
+		}
+
+		names := []string{}
+
+		for _, packageDefinition := range packageDefinitions {
+			names = append(names, packageDefinition.Name)
+		}
+
+		return names, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return reader.names, nil
+}
+
+func parseFilteredYamlDefinition(path string, matchName func(name string) bool) ([]*XdebPackageDefinition, error) {
+	definition, err := parseYamlDefinition(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	packageDefinitions := []*XdebPackageDefinition{}
+
+	for _, packageDefinition := range definition.Xdeb {
+		if matchName(packageDefinition.Name) {
+			packageDefinitions = append(packageDefinitions, packageDefinition)
+		}
+	}
+
+	return packageDefinitions, nil
+}
@@ -0,0 +1,149 @@
+package xdeb
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+const PACKAGE_SUGGESTIONS_MAX = 5
+
+// e.g. libqt5core5a -> libqt5core, libssl3t64 -> libssl
+var SONAME_SUFFIX_PATTERN = regexp.MustCompile(`^(lib.*?[a-z+])-?[0-9][0-9.]*(t64|a|c2|v5|gf)?$`)
+
+type packageSuggestion struct {
+	name      string
+	providers []string
+	score     int
+}
+
+func levenshteinDistance(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
+
+func normalizePackageName(name string) string {
+	name = strings.TrimSuffix(strings.ToLower(name), "-bin")
+
+	if matches := SONAME_SUFFIX_PATTERN.FindStringSubmatch(name); matches != nil {
+		return matches[1]
+	}
+
+	return name
+}
+
+// lower scores are better, negative scores mean no suggestion at all
+func suggestionScore(name string, candidate string) int {
+	if normalizePackageName(name) == normalizePackageName(candidate) {
+		return 0
+	}
+
+	if len(name) > 2 && (strings.HasPrefix(candidate, name) || strings.HasSuffix(candidate, name)) {
+		return 1
+	}
+
+	if len(candidate) > 2 && (strings.HasPrefix(name, candidate) || strings.HasSuffix(name, candidate)) {
+		return 1
+	}
+
+	distance := levenshteinDistance(strings.ToLower(name), strings.ToLower(candidate))
+
+	if distance <= max(2, len(name)/3) {
+		return 1 + distance
+	}
+
+	return -1
+}
+
+func suggestPackages(name string, packageDefinitions []*XdebPackageDefinition) []*packageSuggestion {
+	suggestions := map[string]*packageSuggestion{}
+
+	for _, packageDefinition := range packageDefinitions {
+		suggestion, ok := suggestions[packageDefinition.Name]
+
+		if !ok {
+			score := suggestionScore(name, packageDefinition.Name)
+
+			if score < 0 {
+				continue
+			}
+
+			suggestion = &packageSuggestion{name: packageDefinition.Name, score: score}
+			suggestions[packageDefinition.Name] = suggestion
+		}
+
+		if !slices.Contains(suggestion.providers, packageDefinition.Provider) {
+			suggestion.providers = append(suggestion.providers, packageDefinition.Provider)
+		}
+	}
+
+	sorted := []*packageSuggestion{}
+
+	for _, suggestion := range suggestions {
+		sort.Strings(suggestion.providers)
+		sorted = append(sorted, suggestion)
+	}
+
+	sort.Slice(sorted, func(i int, j int) bool {
+		if sorted[i].score != sorted[j].score {
+			return sorted[i].score < sorted[j].score
+		}
+
+		return sorted[i].name < sorted[j].name
+	})
+
+	if len(sorted) > PACKAGE_SUGGESTIONS_MAX {
+		sorted = sorted[:PACKAGE_SUGGESTIONS_MAX]
+	}
+
+	return sorted
+}
+
+func packageNotFoundError(name string, path string, provider string, distribution string) error {
+	packageDefinitions, err := loadPackageDefinitions(path, provider, distribution, "", false, func(candidate string) bool {
+		return suggestionScore(name, candidate) >= 0
+	})
+
+	if err != nil {
+		return fmt.Errorf("could not find package '%s'", name)
+	}
+
+	suggestions := suggestPackages(name, packageDefinitions)
+
+	if len(suggestions) == 0 {
+		return fmt.Errorf("could not find package '%s'", name)
+	}
+
+	lines := []string{}
+
+	for _, suggestion := range suggestions {
+		lines = append(lines, fmt.Sprintf("  %s (%s)", suggestion.name, strings.Join(suggestion.providers, ", ")))
+	}
+
+	return fmt.Errorf("could not find package '%s', did you mean:\n%s", name, strings.Join(lines, "\n"))
+}
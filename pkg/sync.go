@@ -2,10 +2,17 @@ package xdeb
 
 import (
 	"bufio"
+	"bytes"
 	"compress/gzip"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -15,12 +22,38 @@ import (
 )
 
 type PackageListsProvider struct {
-	Name          string   `yaml:"name"`
-	Custom        bool     `yaml:"custom"`
-	Url           string   `yaml:"url"`
-	Architecture  string   `yaml:"architecture"`
-	Components    []string `yaml:"components"`
-	Distributions []string `yaml:"dists"`
+	Name          string        `yaml:"name" json:"name"`
+	Custom        bool          `yaml:"custom" json:"custom"`
+	Url           string        `yaml:"url" json:"url"`
+	Architecture  string        `yaml:"architecture" json:"architecture"`
+	Components    []string      `yaml:"components,omitempty" json:"components,omitempty"`
+	Distributions []string      `yaml:"dists,omitempty" json:"dists,omitempty"`
+	Mirrors       []string      `yaml:"mirrors,omitempty" json:"mirrors,omitempty"`
+	Auth          *ProviderAuth `yaml:"auth,omitempty" json:"auth,omitempty"`
+}
+
+func (provider *PackageListsProvider) RepositoryUrl() string {
+	if provider.Custom {
+		return fmt.Sprintf("%s/%s/%s", XDEB_INSTALL_REPOSITORIES_URL, XDEB_INSTALL_REPOSITORIES_TAG, provider.Url)
+	}
+
+	return localRepositoryUrl(provider.Url)
+}
+
+// the repository URL is tried first, followed by the mirrors in order
+func (provider *PackageListsProvider) MirrorUrls() []string {
+	urls := []string{provider.RepositoryUrl()}
+
+	for _, mirror := range provider.Mirrors {
+		urls = append(urls, localRepositoryUrl(mirror))
+	}
+
+	return urls
+}
+
+type SyncOptions struct {
+	Contents bool
+	Jobs     int
 }
 
 type PackageListsDefinition struct {
@@ -28,6 +61,42 @@ type PackageListsDefinition struct {
 	Providers []PackageListsProvider `yaml:"providers"`
 }
 
+func parsePackageFields(packageData string) map[string]string {
+	fields := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(packageData))
+	lastField := ""
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// continuation lines of multi-line fields, e.g. the long description
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			if len(lastField) > 0 {
+				line = strings.TrimSpace(line)
+
+				if line == "." {
+					line = ""
+				}
+
+				fields[lastField] = fmt.Sprintf("%s\n%s", fields[lastField], line)
+			}
+
+			continue
+		}
+
+		field, value, found := strings.Cut(line, ":")
+
+		if !found {
+			continue
+		}
+
+		lastField = field
+		fields[field] = strings.TrimSpace(value)
+	}
+
+	return fields
+}
+
 func parsePackagesFile(urlPrefix string, packagesFile string) *XdebProviderDefinition {
 	definition := XdebProviderDefinition{}
 	packages := strings.Split(packagesFile, "\n\n")
@@ -37,96 +106,93 @@ func parsePackagesFile(urlPrefix string, packagesFile string) *XdebProviderDefin
 			continue
 		}
 
-		packageDefinition := XdebPackageDefinition{}
-		scanner := bufio.NewScanner(strings.NewReader(packageData))
-
-		for scanner.Scan() {
-			line := scanner.Text()
-
-			if strings.HasPrefix(line, "Package:") {
-				packageDefinition.Name = line[strings.Index(line, ":")+2:]
-				continue
-			}
-
-			if strings.HasPrefix(line, "Version:") {
-				packageDefinition.Version = line[strings.Index(line, ":")+2:]
-				continue
-			}
-
-			if strings.HasPrefix(line, "Filename:") {
-				suffix := line[strings.Index(line, ":")+2:]
-				packageDefinition.Url = fmt.Sprintf("%s/%s", urlPrefix, suffix)
-				continue
-			}
+		fields := parsePackageFields(packageData)
+		size, _ := strconv.ParseInt(fields["Size"], 10, 64)
+		installedSize, _ := strconv.ParseInt(fields["Installed-Size"], 10, 64)
+
+		packageDefinition := XdebPackageDefinition{
+			Name:          fields["Package"],
+			Version:       fields["Version"],
+			Sha256:        fields["SHA256"],
+			Description:   fields["Description"],
+			Section:       fields["Section"],
+			Maintainer:    fields["Maintainer"],
+			Homepage:      fields["Homepage"],
+			Depends:       fields["Depends"],
+			PreDepends:    fields["Pre-Depends"],
+			Size:          size,
+			InstalledSize: installedSize * 1024, // Installed-Size is given in KiB
+		}
 
-			if strings.HasPrefix(line, "SHA256:") {
-				packageDefinition.Sha256 = line[strings.Index(line, ":")+2:]
-				continue
-			}
+		if filename, ok := fields["Filename"]; ok {
+			packageDefinition.Url = fmt.Sprintf("%s/%s", urlPrefix, filename)
 		}
 
 		definition.Xdeb = append(definition.Xdeb, &packageDefinition)
 	}
 
+	// sorted component files allow lookups to stop early, see streamPackageDefinitions
+	sort.SliceStable(definition.Xdeb, func(i int, j int) bool {
+		return definition.Xdeb[i].Name < definition.Xdeb[j].Name
+	})
+
+	definition.Sorted = true
 	return &definition
 }
 
-func pullPackagesFile(urlPrefix string, dist string, component string, architecture string) (*XdebProviderDefinition, error) {
+// the variant synced last is requested first, so that a conditional request can be sent for it
+func getPackagesFile(ctx context.Context, client *http.Client, urlPrefix string, dist string, component string, architecture string, metadata *ComponentMetadata) (*http.Response, string, error) {
 	requestUrl := fmt.Sprintf(
 		"%s/dists/%s/%s/binary-%s/Packages",
 		urlPrefix, dist, component, architecture,
 	)
 
-	client := NewHttpClient()
-	resp, err := client.Get(requestUrl)
+	requestUrls := []string{requestUrl, fmt.Sprintf("%s.xz", requestUrl), fmt.Sprintf("%s.gz", requestUrl)}
 
-	if err != nil {
-		return nil, err
+	if metadata != nil && slices.Contains(requestUrls, metadata.Url) {
+		requestUrls = append([]string{metadata.Url}, slices.DeleteFunc(requestUrls, func(url string) bool {
+			return url == metadata.Url
+		})...)
 	}
 
-	if resp.StatusCode != 200 {
-		resp, err = client.Get(fmt.Sprintf("%s.xz", requestUrl))
+	for _, requestUrl := range requestUrls {
+		resp, err := conditionalGet(ctx, client, requestUrl, metadata)
 
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
-	}
 
-	if resp.StatusCode != 200 {
-		resp, err = client.Get(fmt.Sprintf("%s.gz", requestUrl))
-
-		if err != nil {
-			return nil, err
+		if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusNotModified {
+			return resp, requestUrl, nil
 		}
-	}
 
-	if resp.StatusCode != 200 {
-		return nil, nil
-	}
+		resp.Body.Close()
 
-	defer resp.Body.Close()
+		// still failing after retrying or rejecting the credentials, as opposed to a missing file
+		if isFailedResponse(resp) {
+			return nil, "", newHttpStatusError(requestUrl, resp)
+		}
+	}
 
-	requestUrl = fmt.Sprintf(
-		"%s://%s%s",
-		resp.Request.URL.Scheme, resp.Request.URL.Host, resp.Request.URL.Path,
-	)
+	return nil, "", nil
+}
 
-	var reader io.Reader
+func parsePackagesBody(urlPrefix string, resp *http.Response, body []byte) (*XdebProviderDefinition, error) {
+	var reader io.Reader = bytes.NewReader(body)
+	var err error
 
-	if strings.HasSuffix(requestUrl, ".xz") {
-		reader, err = xz.NewReader(resp.Body)
+	if strings.HasSuffix(resp.Request.URL.Path, ".xz") {
+		reader, err = xz.NewReader(reader)
 
 		if err != nil {
 			return nil, err
 		}
-	} else if strings.HasSuffix(requestUrl, ".gz") {
-		reader, err = gzip.NewReader(resp.Body)
+	} else if strings.HasSuffix(resp.Request.URL.Path, ".gz") {
+		reader, err = gzip.NewReader(reader)
 
 		if err != nil {
 			return nil, err
 		}
-	} else {
-		reader = resp.Body
 	}
 
 	output, err := io.ReadAll(reader)
@@ -138,41 +204,108 @@ func pullPackagesFile(urlPrefix string, dist string, component string, architect
 	return parsePackagesFile(urlPrefix, string(output)), nil
 }
 
-func pullAptRepository(directory string, url string, dist string, component string, architecture string) error {
-	definition, err := pullPackagesFile(url, dist, component, architecture)
+func pullAptRepository(ctx context.Context, client *http.Client, directory string, url string, dist string, component string, architecture string) (string, error) {
+	filePath := filepath.Join(directory, dist, fmt.Sprintf("%s.yaml", component))
+	metadata := readComponentMetadata(filePath)
+
+	resp, requestUrl, err := getPackagesFile(ctx, client, url, dist, component, architecture, metadata)
 
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	if definition != nil && len(definition.Xdeb) > 0 {
-		LogMessage("Syncing repository %s/%s: %s", filepath.Base(directory), dist, component)
+	if resp == nil {
+		return COMPONENT_SYNC_UNAVAILABLE, nil
+	}
 
-		filePath := filepath.Join(directory, dist, fmt.Sprintf("%s.yaml", component))
-		data, err := yaml.Marshal(definition)
+	defer resp.Body.Close()
 
-		if err != nil {
-			return err
-		}
+	if resp.StatusCode == http.StatusNotModified {
+		return COMPONENT_SYNC_UNCHANGED, nil
+	}
 
-		if _, err = writeFileCompressed(filePath, data); err != nil {
-			return err
-		}
+	body, err := io.ReadAll(resp.Body)
+
+	if err != nil {
+		return "", err
+	}
+
+	newMetadata := newComponentMetadata(requestUrl, resp, body)
+
+	// servers not supporting conditional requests may still serve the very same file
+	if metadata.isUnchanged(newMetadata) {
+		return COMPONENT_SYNC_UNCHANGED, writeComponentMetadata(filePath, newMetadata)
+	}
+
+	definition, err := parsePackagesBody(url, resp, body)
+
+	if err != nil {
+		return "", err
+	}
+
+	if len(definition.Xdeb) == 0 {
+		return COMPONENT_SYNC_UNAVAILABLE, nil
+	}
+
+	LogMessage("Syncing repository %s/%s: %s", filepath.Base(directory), dist, component)
+	data, err := yaml.Marshal(definition)
+
+	if err != nil {
+		return "", err
 	}
 
-	return nil
+	if _, err = writeFileCompressed(filePath, data); err != nil {
+		return "", err
+	}
+
+	return COMPONENT_SYNC_UPDATED, writeComponentMetadata(filePath, newMetadata)
 }
 
-func pullCustomRepository(directory string, urlPrefix string, dist string, component string) error {
-	LogMessage("Syncing repository %s/%s: %s", filepath.Base(urlPrefix), dist, component)
+func pullCustomRepository(ctx context.Context, client *http.Client, directory string, urlPrefix string, dist string, component string) (string, error) {
+	filePath := filepath.Join(directory, dist, fmt.Sprintf("%s.yaml", component))
+	metadata := readComponentMetadata(filePath)
 
 	requestUrl := fmt.Sprintf("%s/%s/%s", urlPrefix, dist, component)
-	_, err := DownloadFile(filepath.Join(directory, dist, fmt.Sprintf("%s.yaml", component)), requestUrl, false, true)
+	resp, err := conditionalGet(ctx, client, requestUrl, metadata)
+
+	if err != nil {
+		return "", fmt.Errorf("could not download file '%s': %w", RedactUrl(requestUrl), err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return COMPONENT_SYNC_UNCHANGED, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", newHttpStatusError(requestUrl, resp)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+
+	if err != nil {
+		return "", err
+	}
+
+	newMetadata := newComponentMetadata(requestUrl, resp, body)
 
-	return err
+	if metadata.isUnchanged(newMetadata) {
+		return COMPONENT_SYNC_UNCHANGED, writeComponentMetadata(filePath, newMetadata)
+	}
+
+	LogMessage("Syncing repository %s/%s: %s", filepath.Base(directory), dist, component)
+
+	if _, err = writeFileCompressed(filePath, body); err != nil {
+		return "", err
+	}
+
+	return COMPONENT_SYNC_UPDATED, writeComponentMetadata(filePath, newMetadata)
 }
 
-func ParsePackageLists() (*PackageListsDefinition, error) {
+// the lists are not downloaded if all providers requested are defined in the config, and the lists synced last
+// are used if they cannot be downloaded, so that local mirrors can be synced without network access
+func ParsePackageLists(providerNames ...string) (*PackageListsDefinition, error) {
 	arch, err := FindArchitecture()
 
 	if err != nil {
@@ -185,18 +318,78 @@ func ParsePackageLists() (*PackageListsDefinition, error) {
 		return nil, err
 	}
 
+	config, err := ParseConfig()
+
+	if err != nil {
+		return nil, err
+	}
+
+	configProviders := len(providerNames) > 0
+
+	for _, providerName := range providerNames {
+		configProviders = configProviders && slices.ContainsFunc(config.Providers, func(provider PackageListsProvider) bool {
+			return provider.Name == providerName
+		})
+	}
+
+	if configProviders {
+		return LoadPackageLists()
+	}
+
 	requestUrl := fmt.Sprintf(
 		"%s/%s/repositories/%s/lists.yaml",
 		XDEB_INSTALL_REPOSITORIES_URL, XDEB_INSTALL_REPOSITORIES_TAG, arch,
 	)
 
 	LogMessage("Syncing lists: %s", requestUrl)
-	listsFile, err := DownloadFile(filepath.Join(path, "lists.yaml"), requestUrl, true, true)
+	listsFile, err := DownloadFile(filepath.Join(path, "lists.yaml"), requestUrl, true)
+
+	if err != nil {
+		lists, loadErr := LoadPackageLists()
+
+		if loadErr != nil || len(lists.Providers) == 0 {
+			return nil, err
+		}
+
+		LogMessage("Could not sync lists, using the lists synced last: %s", err.Error())
+		return lists, nil
+	}
+
+	return parsePackageListsFile(path, listsFile)
+}
+
+// loads the lists synced last without downloading them again, only the providers of the config are available
+// if the lists have never been synced
+func LoadPackageLists() (*PackageListsDefinition, error) {
+	path, err := RepositoryPath()
 
 	if err != nil {
 		return nil, err
 	}
 
+	listsFile := filepath.Join(path, "lists.yaml.zst")
+
+	if _, err := os.Stat(listsFile); os.IsNotExist(err) {
+		config, err := ParseConfig()
+
+		if err != nil {
+			return nil, err
+		}
+
+		providers, err := mergeProviders(nil, config.Providers)
+
+		if err != nil {
+			return nil, err
+		}
+
+		registerProviderAuth(providers)
+		return &PackageListsDefinition{Path: path, Providers: providers}, nil
+	}
+
+	return parsePackageListsFile(path, listsFile)
+}
+
+func parsePackageListsFile(path string, listsFile string) (*PackageListsDefinition, error) {
 	yamlFile, err := decompressFile(listsFile)
 
 	if err != nil {
@@ -210,11 +403,132 @@ func ParsePackageLists() (*PackageListsDefinition, error) {
 		return nil, err
 	}
 
+	config, err := ParseConfig()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if lists.Providers, err = mergeProviders(lists.Providers, config.Providers); err != nil {
+		return nil, err
+	}
+
+	registerProviderAuth(lists.Providers)
+
 	lists.Path = path
 	return lists, nil
 }
 
-func SyncRepositories(lists *PackageListsDefinition, providerNames ...string) error {
+// providers of the config replace the ones of the lists with the same name
+func mergeProviders(providers []PackageListsProvider, userProviders []PackageListsProvider) ([]PackageListsProvider, error) {
+	for _, userProvider := range userProviders {
+		if len(userProvider.Name) == 0 || len(userProvider.Url) == 0 {
+			return nil, fmt.Errorf("providers of the config need a name and a URL")
+		}
+
+		if len(userProvider.Architecture) == 0 {
+			architecture, err := FindDebianArchitecture()
+
+			if err != nil {
+				return nil, err
+			}
+
+			userProvider.Architecture = architecture
+		}
+
+		index := slices.IndexFunc(providers, func(provider PackageListsProvider) bool {
+			return provider.Name == userProvider.Name
+		})
+
+		if index >= 0 {
+			providers[index] = userProvider
+		} else {
+			providers = append(providers, userProvider)
+		}
+	}
+
+	return providers, nil
+}
+
+type componentSyncTask struct {
+	provider     PackageListsProvider
+	directory    string
+	distribution string
+	component    string
+	status       string
+	err          error
+}
+
+func (task *componentSyncTask) run(ctx context.Context, mirrors *mirrorList, options SyncOptions) {
+	if ctx.Err() != nil {
+		task.err = ctx.Err()
+		return
+	}
+
+	urls := task.provider.MirrorUrls()
+	client := newProviderHttpClient(task.provider.Name)
+
+	if task.provider.Custom {
+		task.err = mirrors.pull(urls, func(url string) error {
+			status, err := pullCustomRepository(ctx, client, task.directory, url, task.distribution, task.component)
+			task.status = status
+			return err
+		})
+
+		return
+	}
+
+	status := ""
+	err := mirrors.pull(urls, func(url string) error {
+		var err error
+		status, err = pullAptRepository(ctx, client, task.directory, url, task.distribution, task.component, task.provider.Architecture)
+		return err
+	})
+
+	if err != nil {
+		task.err = err
+		return
+	}
+
+	task.status = status
+}
+
+func (task *componentSyncTask) failed() error {
+	return task.err
+}
+
+// Contents files are synced per distribution, as some repositories provide a single one for all components
+type contentsSyncTask struct {
+	provider     PackageListsProvider
+	directory    string
+	distribution string
+	err          error
+}
+
+func (task *contentsSyncTask) run(ctx context.Context, mirrors *mirrorList, options SyncOptions) {
+	if ctx.Err() != nil {
+		task.err = ctx.Err()
+		return
+	}
+
+	client := newProviderHttpClient(task.provider.Name)
+
+	task.err = mirrors.pull(task.provider.MirrorUrls(), func(url string) error {
+		return pullContentsFiles(ctx, client, task.directory, url, task.distribution, task.provider.Components, task.provider.Architecture)
+	})
+}
+
+func (task *contentsSyncTask) failed() error {
+	return task.err
+}
+
+// components and Contents files are synced by the same workers
+type syncTask interface {
+	run(ctx context.Context, mirrors *mirrorList, options SyncOptions)
+	failed() error
+}
+
+func SyncRepositories(ctx context.Context, lists *PackageListsDefinition, options SyncOptions, providerNames ...string) (*SyncSummary, error) {
 	availableProviderNames := []string{}
 
 	for _, provider := range lists.Providers {
@@ -223,7 +537,7 @@ func SyncRepositories(lists *PackageListsDefinition, providerNames ...string) er
 
 	for _, providerName := range providerNames {
 		if !slices.Contains(availableProviderNames, providerName) {
-			return fmt.Errorf("provider %s not supported, omit or use any of %v", providerName, availableProviderNames)
+			return nil, fmt.Errorf("provider %s not supported, omit or use any of %v", providerName, availableProviderNames)
 		}
 	}
 
@@ -239,45 +553,173 @@ func SyncRepositories(lists *PackageListsDefinition, providerNames ...string) er
 		providers = append(providers, lists.Providers...)
 	}
 
-	operations := len(providers)
-
-	for _, provider := range providers {
-		operations += len(provider.Distributions) * len(provider.Components)
+	if options.Jobs < 1 {
+		return nil, fmt.Errorf("number of jobs must be at least 1")
 	}
 
+	// the first failure cancels all remaining components, the caller's context is canceled on interrupts
+	syncCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	tasks := []*componentSyncTask{}
+	contentsTasks := []*contentsSyncTask{}
+	stagings := map[string]string{}
+
+	defer os.Remove(SyncStagingPath(lists.Path))
+
 	for _, provider := range providers {
-		errors := make(chan error, operations)
-		var wg sync.WaitGroup
+		staging, err := stageProviderDirectory(lists.Path, provider.Name)
+
+		if err != nil {
+			return nil, err
+		}
+
+		defer os.RemoveAll(staging)
+		stagings[provider.Name] = staging
 
 		for _, distribution := range provider.Distributions {
 			for _, component := range provider.Components {
-				wg.Add(1)
-
-				go func(p PackageListsProvider, d string, c string) {
-					defer wg.Done()
-					directory := filepath.Join(lists.Path, p.Name)
-
-					if p.Custom {
-						urlPrefix := fmt.Sprintf("%s/%s/%s", XDEB_INSTALL_REPOSITORIES_URL, XDEB_INSTALL_REPOSITORIES_TAG, p.Url)
-						errors <- pullCustomRepository(directory, urlPrefix, d, c)
-					} else {
-						errors <- pullAptRepository(directory, p.Url, d, c, p.Architecture)
-					}
-				}(provider, distribution, component)
+				tasks = append(tasks, &componentSyncTask{
+					provider:     provider,
+					directory:    staging,
+					distribution: distribution,
+					component:    component,
+				})
+			}
+
+			// custom providers don't provide Contents files
+			if options.Contents && !provider.Custom {
+				contentsTasks = append(contentsTasks, &contentsSyncTask{
+					provider:     provider,
+					directory:    staging,
+					distribution: distribution,
+				})
 			}
 		}
+	}
 
-		wg.Wait()
-		close(errors)
+	queuedTasks := []syncTask{}
 
-		for i := 0; i < operations; i++ {
-			err := <-errors
+	for _, task := range tasks {
+		queuedTasks = append(queuedTasks, task)
+	}
 
-			if err != nil {
-				return err
+	for _, task := range contentsTasks {
+		queuedTasks = append(queuedTasks, task)
+	}
+
+	progress := newSyncProgress(len(tasks)).start()
+	defer progress.stop()
+
+	syncCtx = withProgress(syncCtx, progress)
+	mirrors := newMirrorList()
+	queue := make(chan syncTask)
+	var wg sync.WaitGroup
+
+	for i := 0; i < min(options.Jobs, len(queuedTasks)); i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for task := range queue {
+				task.run(syncCtx, mirrors, options)
+
+				if _, ok := task.(*componentSyncTask); ok {
+					progress.completeComponent()
+				}
+
+				if task.failed() != nil {
+					cancel()
+				}
 			}
+		}()
+	}
+
+	for _, task := range queuedTasks {
+		if syncCtx.Err() != nil {
+			break
+		}
+
+		queue <- task
+	}
+
+	close(queue)
+	wg.Wait()
+	progress.stop()
+
+	summary := &SyncSummary{}
+	failedProviders := map[string]bool{}
+	errs := []error{}
+
+	for _, task := range tasks {
+		switch task.status {
+		case COMPONENT_SYNC_UPDATED:
+			summary.Updated++
+		case COMPONENT_SYNC_UNCHANGED:
+			summary.Unchanged++
+		}
+
+		if len(task.status) == 0 {
+			failedProviders[task.provider.Name] = true
+		}
+
+		// components canceled because of another failure or an interrupt are not worth reporting
+		if task.err != nil && !errors.Is(task.err, context.Canceled) && !errors.Is(task.err, context.DeadlineExceeded) {
+			errs = append(errs, fmt.Errorf(
+				"could not sync repository %s/%s: %s: %w",
+				task.provider.Name, task.distribution, task.component, task.err,
+			))
+		}
+	}
+
+	for _, task := range contentsTasks {
+		if task.err != nil {
+			failedProviders[task.provider.Name] = true
+		}
+
+		if task.err != nil && !errors.Is(task.err, context.Canceled) && !errors.Is(task.err, context.DeadlineExceeded) {
+			errs = append(errs, fmt.Errorf("could not sync contents %s/%s: %w", task.provider.Name, task.distribution, task.err))
+		}
+	}
+
+	pruned := false
+
+	// a provider directory is left untouched unless all of its components have been synced
+	for _, provider := range providers {
+		if failedProviders[provider.Name] {
+			continue
+		}
+
+		removed, err := pruneProviderDirectory(stagings[provider.Name], provider)
+
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		pruned = pruned || removed
+
+		if err := commitProviderDirectory(lists.Path, provider.Name, stagings[provider.Name]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	if len(failedProviders) > 0 {
+		return nil, fmt.Errorf("sync interrupted, repositories of %d providers left unchanged: %w", len(failedProviders), ctx.Err())
+	}
+
+	// an index built from the very same component files is still current, unless it is corrupt
+	if summary.Updated == 0 && !pruned {
+		if index, err := openPackageIndex(lists.Path); err == nil {
+			index.Close()
+			return summary, nil
 		}
 	}
 
-	return nil
+	return summary, BuildPackageIndex(lists.Path)
 }
@@ -0,0 +1,103 @@
+package xdeb
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const COMPONENT_SYNC_UPDATED = "updated"
+const COMPONENT_SYNC_UNCHANGED = "unchanged"
+const COMPONENT_SYNC_UNAVAILABLE = "unavailable"
+
+// stored next to each synced component file (e.g. main.yaml.zst -> main.meta.yaml, main.contents.zst -> main.contents.meta.yaml)
+type ComponentMetadata struct {
+	Url          string `yaml:"url"`
+	ETag         string `yaml:"etag,omitempty"`
+	LastModified string `yaml:"last_modified,omitempty"`
+	Sha256       string `yaml:"sha256,omitempty"`
+}
+
+type SyncSummary struct {
+	Updated   int
+	Unchanged int
+}
+
+func componentMetadataPath(path string) string {
+	return fmt.Sprintf("%s.meta.yaml", strings.TrimSuffix(path, ".yaml"))
+}
+
+// metadata is only used as long as the component file it belongs to exists
+func readComponentMetadata(path string) *ComponentMetadata {
+	if _, err := os.Stat(fmt.Sprintf("%s.zst", path)); err != nil {
+		return nil
+	}
+
+	data, err := os.ReadFile(componentMetadataPath(path))
+
+	if err != nil {
+		return nil
+	}
+
+	metadata := &ComponentMetadata{}
+
+	if err = yaml.Unmarshal(data, metadata); err != nil {
+		return nil
+	}
+
+	return metadata
+}
+
+func writeComponentMetadata(path string, metadata *ComponentMetadata) error {
+	data, err := yaml.Marshal(metadata)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = writeFile(componentMetadataPath(path), data)
+	return err
+}
+
+func newComponentMetadata(requestUrl string, resp *http.Response, body []byte) *ComponentMetadata {
+	checksum := sha256.Sum256(body)
+
+	return &ComponentMetadata{
+		Url:          requestUrl,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Sha256:       hex.EncodeToString(checksum[:]),
+	}
+}
+
+// package URLs of APT repositories are built from the URL synced, an identical file from another URL is no change therefore
+func (metadata *ComponentMetadata) isUnchanged(newMetadata *ComponentMetadata) bool {
+	return metadata != nil && metadata.Url == newMetadata.Url && metadata.Sha256 == newMetadata.Sha256
+}
+
+// sends a conditional request if the URL has been synced before
+func conditionalGet(ctx context.Context, client *http.Client, requestUrl string, metadata *ComponentMetadata) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, requestUrl, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if metadata != nil && metadata.Url == requestUrl {
+		if len(metadata.ETag) > 0 {
+			request.Header.Set("If-None-Match", metadata.ETag)
+		}
+
+		if len(metadata.LastModified) > 0 {
+			request.Header.Set("If-Modified-Since", metadata.LastModified)
+		}
+	}
+
+	return client.Do(request)
+}
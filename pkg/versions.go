@@ -0,0 +1,89 @@
+package xdeb
+
+import (
+	"fmt"
+	"sort"
+
+	"golang.org/x/exp/slices"
+)
+
+type XdebPackageVersions struct {
+	Name         string                   `yaml:"name" json:"name"`
+	Provider     string                   `yaml:"provider" json:"provider"`
+	Distribution string                   `yaml:"distribution" json:"distribution"`
+	Versions     []*XdebPackageDefinition `yaml:"versions" json:"versions"`
+}
+
+func GroupPackageVersions(packageDefinitions []*XdebPackageDefinition) []*XdebPackageVersions {
+	groups := map[string]*XdebPackageVersions{}
+	keys := []string{}
+
+	for _, packageDefinition := range packageDefinitions {
+		key := fmt.Sprintf("%s/%s/%s", packageDefinition.Name, packageDefinition.Provider, packageDefinition.Distribution)
+		group, ok := groups[key]
+
+		if !ok {
+			group = &XdebPackageVersions{
+				Name:         packageDefinition.Name,
+				Provider:     packageDefinition.Provider,
+				Distribution: packageDefinition.Distribution,
+			}
+
+			groups[key] = group
+			keys = append(keys, key)
+		}
+
+		group.Versions = append(group.Versions, packageDefinition)
+	}
+
+	sort.Strings(keys)
+	versions := []*XdebPackageVersions{}
+
+	for _, key := range keys {
+		group := groups[key]
+
+		sort.SliceStable(group.Versions, func(i int, j int) bool {
+			return compareVersions(group.Versions[i].Version, group.Versions[j].Version) > 0
+		})
+
+		versions = append(versions, group)
+	}
+
+	return versions
+}
+
+// keeps the newest candidate of each package per provider and distribution, expects candidates sorted by version
+func LatestPackageDefinitions(packageDefinitions []*XdebPackageDefinition) []*XdebPackageDefinition {
+	seen := map[string]bool{}
+	latest := []*XdebPackageDefinition{}
+
+	for _, packageDefinition := range packageDefinitions {
+		key := fmt.Sprintf("%s/%s/%s", packageDefinition.Name, packageDefinition.Provider, packageDefinition.Distribution)
+
+		if !seen[key] {
+			seen[key] = true
+			latest = append(latest, packageDefinition)
+		}
+	}
+
+	return latest
+}
+
+func FilterPackageVersion(packageDefinitions []*XdebPackageDefinition, packageVersion string) ([]*XdebPackageDefinition, error) {
+	filtered := []*XdebPackageDefinition{}
+	available := []string{}
+
+	for _, packageDefinition := range packageDefinitions {
+		if packageDefinition.Version == packageVersion {
+			filtered = append(filtered, packageDefinition)
+		} else if !slices.Contains(available, packageDefinition.Version) {
+			available = append(available, packageDefinition.Version)
+		}
+	}
+
+	if len(filtered) == 0 {
+		return nil, fmt.Errorf("version '%s' of package '%s' not found, use any of %v", packageVersion, packageDefinitions[0].Name, available)
+	}
+
+	return filtered, nil
+}
@@ -1,45 +1,72 @@
 package xdeb
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 
 	"github.com/urfave/cli/v2"
 )
 
-func comparePackageChecksums(path string, expected string) error {
-	hasher := sha256.New()
-	contents, err := os.ReadFile(path)
+func compareChecksums(actual string, expected string) error {
+	if actual != expected {
+		return fmt.Errorf("checksums don't match: actual=%s expected=%s", actual, expected)
+	}
+
+	return nil
+}
+
+func xbpsPackageName(pkgver string) string {
+	index := strings.LastIndex(pkgver, "-")
+
+	if index < 0 {
+		return pkgver
+	}
+
+	return pkgver[:index]
+}
+
+// maps package names to their pkgver, empty on systems without XBPS
+func InstalledXbpsPackages() (map[string]string, error) {
+	installed := map[string]string{}
+	output, err := exec.Command("xbps-query", "-l").Output()
 
 	if err != nil {
-		return err
+		if errors.Is(err, exec.ErrNotFound) {
+			return installed, nil
+		}
+
+		return nil, err
 	}
 
-	hasher.Write(contents)
-	actual := hex.EncodeToString(hasher.Sum(nil))
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
 
-	if actual != expected {
-		return fmt.Errorf("checksums don't match: actual=%s expected=%s", actual, expected)
+		if len(fields) < 2 {
+			continue
+		}
+
+		installed[xbpsPackageName(fields[1])] = fields[1]
 	}
 
-	return nil
+	return installed, nil
 }
 
-func installPackage(path string) error {
+func installPackage(path string) (string, error) {
 	workdir := filepath.Dir(path)
 	binpkgs := filepath.Join(workdir, "binpkgs")
 
 	files, err := filepath.Glob(filepath.Join(binpkgs, "*.xbps"))
 
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	if len(files) == 0 {
-		return fmt.Errorf("could not find any XBPS packages to install within '%s'", binpkgs)
+		return "", fmt.Errorf("could not find any XBPS packages to install within '%s'", binpkgs)
 	}
 
 	xbps := TrimPathExtension(filepath.Base(files[0]), 2)
@@ -50,7 +77,7 @@ func installPackage(path string) error {
 	}
 
 	args = append(args, "xbps-install", "-R", "binpkgs", xbps)
-	return ExecuteCommand(workdir, args...)
+	return xbpsPackageName(xbps), ExecuteCommand(workdir, args...)
 }
 
 func InstallPackage(packageDefinition *XdebPackageDefinition, context *cli.Context) error {
@@ -59,7 +86,7 @@ func InstallPackage(packageDefinition *XdebPackageDefinition, context *cli.Conte
 	if packageDefinition.Provider == "localhost" {
 		LogMessage("Installing %s from %s", packageDefinition.Name, packageDefinition.FilePath)
 	} else if packageDefinition.Provider == "remote" {
-		LogMessage("Installing %s from %s", packageDefinition.Name, packageDefinition.Url)
+		LogMessage("Installing %s from %s", packageDefinition.Name, RedactUrl(packageDefinition.Url))
 	} else {
 		LogMessage(
 			"Installing %s from %s @ %s/%s",
@@ -67,17 +94,19 @@ func InstallPackage(packageDefinition *XdebPackageDefinition, context *cli.Conte
 		)
 	}
 
-	// download if an URL is provided
+	checksum := ""
+
+	// download if an URL is provided, the checksum is computed while downloading
 	if len(packageDefinition.Url) > 0 {
-		err := os.RemoveAll(packageDefinition.Path)
+		err := removeAllExceptPartialDownloads(packageDefinition.Path)
 
 		if err != nil {
 			return err
 		}
 
-		packageDefinition.FilePath, err = DownloadFile(
+		packageDefinition.FilePath, checksum, err = downloadPackage(
+			packageDefinition,
 			filepath.Join(packageDefinition.Path, fmt.Sprintf("%s.deb", packageDefinition.Name)),
-			packageDefinition.Url, true, false,
 		)
 
 		if err != nil {
@@ -87,7 +116,16 @@ func InstallPackage(packageDefinition *XdebPackageDefinition, context *cli.Conte
 
 	// compare checksums if available
 	if len(packageDefinition.Sha256) > 0 {
-		if err := comparePackageChecksums(packageDefinition.FilePath, packageDefinition.Sha256); err != nil {
+		if len(checksum) == 0 {
+			var err error
+			checksum, err = fileChecksum(packageDefinition.FilePath)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := compareChecksums(checksum, packageDefinition.Sha256); err != nil {
 			return err
 		}
 	}
@@ -98,7 +136,9 @@ func InstallPackage(packageDefinition *XdebPackageDefinition, context *cli.Conte
 	}
 
 	// xbps-install
-	if err := installPackage(packageDefinition.FilePath); err != nil {
+	xbpsPackage, err := installPackage(packageDefinition.FilePath)
+
+	if err != nil {
 		return err
 	}
 
@@ -107,6 +147,11 @@ func InstallPackage(packageDefinition *XdebPackageDefinition, context *cli.Conte
 		return err
 	}
 
+	// remember what has been installed via xdeb-install
+	if err := recordInstalledPackage(packageDefinition, xbpsPackage); err != nil {
+		return err
+	}
+
 	// cleanup
 	return os.RemoveAll(packageDefinition.Path)
 }
@@ -1,13 +1,15 @@
 package xdeb
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"sort"
-	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/adrg/xdg"
 	version "github.com/knqyf263/go-deb-version"
@@ -15,27 +17,35 @@ import (
 )
 
 type XdebPackagePostInstallCommandDefinition struct {
-	Root    bool   `yaml:"root"`
-	Command string `yaml:"command"`
+	Root    bool   `yaml:"root" json:"root"`
+	Command string `yaml:"command" json:"command"`
 }
 
 type XdebPackagePostInstallDefinition struct {
-	Name     string                                    `yaml:"name"`
-	Commands []XdebPackagePostInstallCommandDefinition `yaml:"commands"`
+	Name     string                                    `yaml:"name" json:"name"`
+	Commands []XdebPackagePostInstallCommandDefinition `yaml:"commands" json:"commands"`
 }
 
 type XdebPackageDefinition struct {
-	Name         string                             `yaml:"name"`
-	Version      string                             `yaml:"version"`
-	Url          string                             `yaml:"url"`
-	Sha256       string                             `yaml:"sha256"`
-	PostInstall  []XdebPackagePostInstallDefinition `yaml:"post-install,omitempty"`
-	Path         string                             `yaml:"path,omitempty"`
-	FilePath     string                             `yaml:"filepath,omitempty"`
-	Provider     string                             `yaml:"provider,omitempty"`
-	Distribution string                             `yaml:"distribution,omitempty"`
-	Component    string                             `yaml:"component,omitempty"`
-	IsConfigured bool                               `yaml:"is_configured,omitempty"`
+	Name          string                             `yaml:"name" json:"name"`
+	Version       string                             `yaml:"version" json:"version"`
+	Url           string                             `yaml:"url" json:"url"`
+	Sha256        string                             `yaml:"sha256" json:"sha256"`
+	Description   string                             `yaml:"description,omitempty" json:"description,omitempty"`
+	Section       string                             `yaml:"section,omitempty" json:"section,omitempty"`
+	Maintainer    string                             `yaml:"maintainer,omitempty" json:"maintainer,omitempty"`
+	Homepage      string                             `yaml:"homepage,omitempty" json:"homepage,omitempty"`
+	Depends       string                             `yaml:"depends,omitempty" json:"depends,omitempty"`
+	PreDepends    string                             `yaml:"pre-depends,omitempty" json:"pre-depends,omitempty"`
+	Size          int64                              `yaml:"size,omitempty" json:"size,omitempty"`
+	InstalledSize int64                              `yaml:"installed-size,omitempty" json:"installed-size,omitempty"`
+	PostInstall   []XdebPackagePostInstallDefinition `yaml:"post-install,omitempty" json:"post-install,omitempty"`
+	Path          string                             `yaml:"path,omitempty" json:"-"`
+	FilePath      string                             `yaml:"filepath,omitempty" json:"-"`
+	Provider      string                             `yaml:"provider,omitempty" json:"provider,omitempty"`
+	Distribution  string                             `yaml:"distribution,omitempty" json:"distribution,omitempty"`
+	Component     string                             `yaml:"component,omitempty" json:"component,omitempty"`
+	IsConfigured  bool                               `yaml:"is_configured,omitempty" json:"-"`
 }
 
 func (packageDefinition *XdebPackageDefinition) setProvider() {
@@ -104,7 +114,8 @@ func (packageDefinition *XdebPackageDefinition) runPostInstallHooks() error {
 }
 
 type XdebProviderDefinition struct {
-	Xdeb []*XdebPackageDefinition `yaml:"xdeb"`
+	Sorted bool                     `yaml:"sorted,omitempty"`
+	Xdeb   []*XdebPackageDefinition `yaml:"xdeb"`
 }
 
 func parseYamlDefinition(path string) (*XdebProviderDefinition, error) {
@@ -124,8 +135,23 @@ func parseYamlDefinition(path string) (*XdebProviderDefinition, error) {
 	return &definition, nil
 }
 
-func FindPackage(name string, path string, provider string, distribution string, exact bool) ([]*XdebPackageDefinition, error) {
-	LogMessage("Looking for package %s (exact: %s) via provider %s and distribution %s ...", name, strconv.FormatBool(exact), provider, distribution)
+// prefers the package index built at sync time, falls back to parsing all component files
+func loadPackageDefinitions(path string, provider string, distribution string, prefix string, exact bool, matchName func(name string) bool) ([]*XdebPackageDefinition, error) {
+	if index, err := openOrRebuildPackageIndex(path); err == nil {
+		packageDefinitions, err := index.find(provider, distribution, prefix, exact, matchName)
+		index.Close()
+
+		// corrupt record blocks are only noticed while reading them, the next lookup uses a new index
+		if !errors.Is(err, errPackageIndexCorrupt) {
+			return packageDefinitions, err
+		}
+
+		LogMessage("Package index is corrupt, rebuilding it")
+
+		if err = BuildPackageIndex(path); err != nil {
+			return nil, err
+		}
+	}
 
 	globPattern := filepath.Join(path, provider, distribution, "*.yaml.zst")
 	globbed, err := filepath.Glob(globPattern)
@@ -138,51 +164,130 @@ func FindPackage(name string, path string, provider string, distribution string,
 		return nil, fmt.Errorf("no repositories present on the system, please sync repositories first")
 	}
 
+	results, err := parseComponentFiles(globbed, prefix, exact, matchName)
+
+	if err != nil {
+		return nil, err
+	}
+
 	packageDefinitions := []*XdebPackageDefinition{}
 
-	for _, match := range globbed {
-		definition, err := parseYamlDefinition(match)
+	for _, result := range results {
+		packageDefinitions = append(packageDefinitions, result...)
+	}
 
-		if err != nil {
-			return nil, err
-		}
+	return packageDefinitions, nil
+}
+
+func setComponentLocation(packageDefinitions []*XdebPackageDefinition, path string) {
+	distPath := filepath.Dir(path)
+
+	for _, packageDefinition := range packageDefinitions {
+		packageDefinition.Component = TrimPathExtension(filepath.Base(path), 2)
+		packageDefinition.Distribution = filepath.Base(distPath)
+		packageDefinition.Provider = filepath.Base(filepath.Dir(distPath))
+	}
+}
+
+// component files are parsed by a bounded number of workers, results keep the order of the files
+func parseComponentFiles(files []string, prefix string, exact bool, matchName func(name string) bool) ([][]*XdebPackageDefinition, error) {
+	results := make([][]*XdebPackageDefinition, len(files))
+	fileErrors := make([]error, len(files))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
 
-		for index := range definition.Xdeb {
-			if (exact && definition.Xdeb[index].Name == name) || (!exact && strings.HasPrefix(definition.Xdeb[index].Name, name)) {
-				distPath := filepath.Dir(match)
+	for worker := 0; worker < min(runtime.NumCPU(), len(files)); worker++ {
+		wg.Add(1)
 
-				definition.Xdeb[index].Component = TrimPathExtension(filepath.Base(match), 2)
-				definition.Xdeb[index].Distribution = filepath.Base(distPath)
-				definition.Xdeb[index].Provider = filepath.Base(filepath.Dir(distPath))
+		go func() {
+			defer wg.Done()
 
-				packageDefinitions = append(packageDefinitions, definition.Xdeb[index])
+			for job := range jobs {
+				results[job], fileErrors[job] = streamPackageDefinitions(files[job], prefix, exact, matchName)
+				setComponentLocation(results[job], files[job])
 			}
-		}
+		}()
 	}
 
-	if len(packageDefinitions) == 0 {
-		return nil, fmt.Errorf("could not find package '%s'", name)
+	for job := range files {
+		jobs <- job
 	}
 
-	sort.Slice(packageDefinitions, func(i int, j int) bool {
-		versionA, err := version.NewVersion(packageDefinitions[i].Version)
+	close(jobs)
+	wg.Wait()
 
+	for _, err := range fileErrors {
 		if err != nil {
-			return false
+			return nil, err
 		}
+	}
 
-		versionB, err := version.NewVersion(packageDefinitions[j].Version)
+	return results, nil
+}
 
-		if err != nil {
-			return false
+func LoadPackageDefinitions(path string, provider string, distribution string) ([]*XdebPackageDefinition, error) {
+	return loadPackageDefinitions(path, provider, distribution, "", false, func(name string) bool {
+		return true
+	})
+}
+
+func FindPackage(name string, path string, provider string, distribution string, matchMode string) ([]*XdebPackageDefinition, error) {
+	LogMessage("Looking for package %s (match: %s) via provider %s and distribution %s ...", name, matchMode, provider, distribution)
+
+	matchName, err := newPackageMatcher(name, matchMode)
+
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := ""
+
+	if matchMode == PACKAGE_MATCH_EXACT || matchMode == PACKAGE_MATCH_PREFIX {
+		prefix = name
+	}
+
+	packageDefinitions, err := loadPackageDefinitions(path, provider, distribution, prefix, matchMode == PACKAGE_MATCH_EXACT, matchName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(packageDefinitions) == 0 {
+		if matchMode == PACKAGE_MATCH_GLOB || matchMode == PACKAGE_MATCH_REGEX {
+			return nil, fmt.Errorf("could not find any package matching '%s'", name)
 		}
 
-		return versionA.GreaterThan(versionB)
+		return nil, packageNotFoundError(name, path, provider, distribution)
+	}
+
+	sort.SliceStable(packageDefinitions, func(i int, j int) bool {
+		return compareVersions(packageDefinitions[i].Version, packageDefinitions[j].Version) > 0
 	})
 
 	return packageDefinitions, nil
 }
 
+func compareVersions(a string, b string) int {
+	versionA, errA := version.NewVersion(a)
+	versionB, errB := version.NewVersion(b)
+
+	if errA != nil || errB != nil {
+		// unparsable (or missing) versions always lose against valid ones
+		if errA == nil {
+			return 1
+		}
+
+		if errB == nil {
+			return -1
+		}
+
+		return 0
+	}
+
+	return versionA.Compare(versionB)
+}
+
 func RepositoryPath() (string, error) {
 	arch, err := FindArchitecture()
 
@@ -212,3 +317,9 @@ func convertPackage(path string, xdebArgs string) error {
 	xdebPath, _ := FindXdeb()
 	return ExecuteCommand(filepath.Dir(path), xdebPath, xdebArgs, path)
 }
+
+type XdebPackageDetails struct {
+	XdebPackageDefinition `yaml:",inline"`
+	Installed             *XdebInstallRecord `yaml:"installed,omitempty" json:"installed,omitempty"`
+	Status                *XdebInstallStatus `yaml:"status" json:"status"`
+}
@@ -1,4 +1,17 @@
+import contextlib
+import functools
+import gzip
+import hashlib
+import http.server
+import io
+import os
 import subprocess
+import tarfile
+import threading
+import time
+import urllib.parse
+
+from pathlib import Path
 
 from . import constants
 
@@ -33,3 +46,140 @@ def assert_xdeb_installation(version: str = None):
 
     assert_xdeb_install_command(*args)
     subprocess.check_call([constants.XDEB_BINARY_PATH, "-h"])
+
+
+def _ar_member(name: str, data: bytes) -> bytes:
+    header = f"{name:<16}{0:<12}{0:<6}{0:<6}{100644:<8}{len(data):<10}`\n".encode()
+    return header + data + (b"\n" if len(data) % 2 else b"")
+
+
+def _tar_gz(files: dict) -> bytes:
+    buffer = io.BytesIO()
+
+    with tarfile.open(fileobj=buffer, mode="w:gz") as archive:
+        for name, data in files.items():
+            info = tarfile.TarInfo(name)
+            info.size = len(data)
+            archive.addfile(info, io.BytesIO(data))
+
+    return buffer.getvalue()
+
+
+def create_deb(path: Path, name: str, version: str, depends: str = None):
+    control = f"Package: {name}\nVersion: {version}\nArchitecture: amd64\nMaintainer: xdeb-install <test@localhost>\n"
+
+    if depends is not None:
+        control += f"Depends: {depends}\n"
+
+    control += f"Description: {name} test package\n"
+    data = _tar_gz({f"./usr/share/doc/{name}/README": f"{name} {version}\n".encode()})
+
+    path.parent.mkdir(parents=True, exist_ok=True)
+    path.write_bytes(
+        b"!<arch>\n"
+        + _ar_member("debian-binary", b"2.0\n")
+        + _ar_member("control.tar.gz", _tar_gz({"./control": control.encode()}))
+        + _ar_member("data.tar.gz", data)
+    )
+
+
+def create_mirror(mirror: Path, packages: list):
+    """
+    Creates an APT mirror of (name, version, depends) packages with distribution "stable" and component "main".
+    """
+    entries = []
+
+    for name, version, depends in packages:
+        filename = f"pool/main/{name[0]}/{name}_{version}_amd64.deb"
+        deb = mirror.joinpath(filename)
+        create_deb(deb, name, version, depends)
+
+        entry = f"Package: {name}\nVersion: {version}\nArchitecture: amd64\nFilename: {filename}\n"
+        entry += f"Size: {deb.stat().st_size}\nSHA256: {hashlib.sha256(deb.read_bytes()).hexdigest()}\n"
+
+        if depends is not None:
+            entry += f"Depends: {depends}\n"
+
+        entries.append(entry + f"Description: {name} test package\n")
+
+    packages_path = mirror.joinpath("dists", "stable", "main", "binary-amd64", "Packages.gz")
+    packages_path.parent.mkdir(parents=True)
+    packages_path.write_bytes(gzip.compress("\n".join(entries).encode()))
+
+
+def create_local_mirror(tmp_path: Path, packages: list) -> dict:
+    """
+    Creates an APT mirror of (name, version, depends) packages in tmp_path and a config providing it as "local",
+    returns the environment to run xdeb-install with.
+    """
+    mirror = tmp_path.joinpath("mirror")
+    create_mirror(mirror, packages)
+
+    return create_config(
+        tmp_path,
+        f"providers:\n  - name: local\n    url: {mirror}\n    architecture: amd64\n    dists: [stable]\n    components: [main]\n"
+    )
+
+
+def create_config(tmp_path: Path, config: str) -> dict:
+    """
+    Writes the config to tmp_path, returns the environment to run xdeb-install with.
+    """
+    path = tmp_path.joinpath("config", "xdeb-install", "config.yaml")
+    path.parent.mkdir(parents=True, exist_ok=True)
+    path.write_text(config)
+
+    return {
+        **os.environ,
+        "XDG_CONFIG_HOME": str(tmp_path.joinpath("config")),
+        "XDG_DATA_HOME": str(tmp_path.joinpath("data")),
+        "XDG_CACHE_HOME": str(tmp_path.joinpath("cache")),
+    }
+
+
+class _MirrorRequestHandler(http.server.SimpleHTTPRequestHandler):
+    def __init__(self, *args, failing: dict, requests: list, delay: float, **kwargs):
+        self.failing = failing
+        self.requests = requests
+        self.delay = delay
+        super().__init__(*args, **kwargs)
+
+    def send_head(self):
+        self.requests.append(self.path)
+        time.sleep(self.delay)
+
+        # requests sent to the server as proxy carry the whole URL
+        if self.path.startswith("http://"):
+            self.path = urllib.parse.urlsplit(self.path).path
+
+        for prefix, status in self.failing.items():
+            if self.path.startswith(prefix):
+                self.send_error(status)
+                return None
+
+        return super().send_head()
+
+    def log_message(self, format, *args):
+        pass
+
+
+@contextlib.contextmanager
+def serve_http(directory: Path, failing: dict, delay: float = 0):
+    """
+    Serves the directory via HTTP, also as a proxy for any host, requests of paths starting with any prefix of failing
+    are answered with its status, yields the URL of the server and the paths (or URLs if proxied) requested.
+    The failing prefixes may be changed while serving, every request is answered after delay seconds.
+    """
+    requests = []
+    handler = functools.partial(
+        _MirrorRequestHandler, directory=str(directory), failing=failing, requests=requests, delay=delay
+    )
+    server = http.server.ThreadingHTTPServer(("127.0.0.1", 0), handler)
+    thread = threading.Thread(target=server.serve_forever, daemon=True)
+    thread.start()
+
+    try:
+        yield f"http://127.0.0.1:{server.server_address[1]}", requests
+    finally:
+        server.shutdown()
+        server.server_close()
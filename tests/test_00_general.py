@@ -1,3 +1,4 @@
+import subprocess
 import pytest
 
 from . import helpers
@@ -11,3 +12,12 @@ def test_version():
 @pytest.mark.order(1)
 def test_help():
     helpers.assert_xdeb_install_command("--help")
+
+
+@pytest.mark.order(2)
+def test_completion():
+    for shell in ("bash", "zsh", "fish"):
+        helpers.assert_xdeb_install_command("completion", shell)
+
+    with pytest.raises(subprocess.CalledProcessError):
+        helpers.assert_xdeb_install_command("completion", "tcsh")
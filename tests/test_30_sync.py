@@ -1,3 +1,11 @@
+import gzip
+import json
+import os
+import pty
+import re
+import shutil
+import struct
+import subprocess
 import pytest
 
 from . import constants
@@ -18,3 +26,325 @@ def test_sync_single():
 @pytest.mark.order(32)
 def test_sync_each():
     helpers.assert_xdeb_install_command("sync", *constants.XDEB_INSTALL_PROVIDERS)
+
+
+@pytest.mark.order(33)
+def test_sync_unchanged():
+    helpers.assert_xdeb_install_command("sync")
+    output = subprocess.check_output([constants.XDEB_INSTALL_BINARY_PATH, "sync"]).decode()
+
+    assert "Finished syncing" in output
+    assert re.search(r"\b0 unchanged", output) is None
+
+
+@pytest.mark.order(34)
+def test_sync_jobs():
+    helpers.assert_xdeb_install_command("--jobs", "1", "sync")
+
+    with pytest.raises(subprocess.CalledProcessError):
+        helpers.assert_xdeb_install_command("--jobs", "0", "sync")
+
+
+@pytest.mark.order(35)
+def test_sync_local_mirror(tmp_path):
+    # config providers are synced without downloading the lists, so no network access is needed
+    env = helpers.create_local_mirror(tmp_path, [("xdeb-install-local-hello", "1.0", None)])
+    mirror = tmp_path.joinpath("mirror")
+
+    subprocess.check_call([constants.XDEB_INSTALL_BINARY_PATH, "sync", "local"], env=env)
+    output = subprocess.check_output([constants.XDEB_INSTALL_BINARY_PATH, "search", "xdeb-install-local-hello"], env=env).decode()
+
+    assert f"file://{mirror}/pool/main/x/xdeb-install-local-hello_1.0_amd64.deb" in output
+
+    output = subprocess.check_output([constants.XDEB_INSTALL_BINARY_PATH, "sync", "local"], env=env).decode()
+    assert "0 components updated, 1 unchanged" in output
+
+
+@pytest.mark.order(36)
+def test_sync_corrupt_index(tmp_path):
+    env = helpers.create_local_mirror(tmp_path, [("xdeb-install-local-hello", "1.0", None)])
+    subprocess.check_call([constants.XDEB_INSTALL_BINARY_PATH, "sync", "local"], env=env)
+
+    index = next(tmp_path.rglob("index.bin"))
+    data = bytearray(index.read_bytes())
+
+    # the length of the entries table in the footer
+    struct.pack_into("<Q", data, len(data) - 24, 2 ** 40)
+    index.write_bytes(bytes(data))
+
+    for _ in range(2):
+        output = subprocess.check_output([constants.XDEB_INSTALL_BINARY_PATH, "search", "xdeb-install-local-hello"], env=env).decode()
+        assert "package: xdeb-install-local-hello" in output
+
+        # truncated indexes are rebuilt as well
+        index.write_bytes(index.read_bytes()[:100])
+
+
+@pytest.mark.order(36)
+def test_sync_removed_component(tmp_path):
+    env = helpers.create_local_mirror(tmp_path, [("xdeb-install-local-hello", "1.0", None)])
+    subprocess.check_call([constants.XDEB_INSTALL_BINARY_PATH, "sync", "local"], env=env)
+
+    config = tmp_path.joinpath("config", "xdeb-install", "config.yaml")
+    config.write_text(config.read_text().replace("[main]", "[contrib]"))
+    subprocess.check_call([constants.XDEB_INSTALL_BINARY_PATH, "sync", "local"], env=env)
+
+    with pytest.raises(subprocess.CalledProcessError):
+        subprocess.check_call([constants.XDEB_INSTALL_BINARY_PATH, "search", "xdeb-install-local-hello"], env=env)
+
+
+@pytest.mark.order(36)
+def test_sync_contents_unchanged(tmp_path):
+    env = helpers.create_local_mirror(tmp_path, [("xdeb-install-local-hello", "1.0", None)])
+    contents = tmp_path.joinpath("mirror", "dists", "stable", "main", "Contents-amd64.gz")
+    contents.write_bytes(gzip.compress(b"usr/bin/xdeb-install-local-hello    utils/xdeb-install-local-hello\n"))
+
+    output = subprocess.check_output([constants.XDEB_INSTALL_BINARY_PATH, "sync", "--contents", "local"], env=env).decode()
+    assert "Syncing contents" in output
+
+    # Contents files are only downloaded again if modified
+    output = subprocess.check_output([constants.XDEB_INSTALL_BINARY_PATH, "sync", "--contents", "local"], env=env).decode()
+    assert "Syncing contents" not in output
+
+    output = subprocess.check_output([constants.XDEB_INSTALL_BINARY_PATH, "provides", "bin/xdeb-install-local-hello"], env=env).decode()
+    assert "xdeb-install-local-hello" in output
+
+
+@pytest.mark.order(36)
+def test_sync_distribution_contents(tmp_path):
+    env = helpers.create_local_mirror(tmp_path, [("xdeb-install-local-hello", "1.0", None)])
+    config = tmp_path.joinpath("config", "xdeb-install", "config.yaml")
+    config.write_text(config.read_text().replace("components: [main]", "components: [main, contrib]"))
+
+    contrib = tmp_path.joinpath("mirror", "dists", "stable", "contrib", "binary-amd64", "Packages.gz")
+    contrib.parent.mkdir(parents=True)
+    contrib.write_bytes(gzip.compress(b""))
+
+    # whole-distribution Contents files qualify packages outside of main with their component
+    contents = tmp_path.joinpath("mirror", "dists", "stable", "Contents-amd64.gz")
+    contents.write_bytes(gzip.compress(
+        b"usr/bin/xdeb-install-local-hello    utils/xdeb-install-local-hello\n"
+        b"usr/bin/xdeb-install-local-extra    contrib/utils/xdeb-install-local-extra\n"
+    ))
+
+    with helpers.serve_http(tmp_path, {}) as (url, requests):
+        config.write_text(config.read_text().replace(f"url: {tmp_path.joinpath('mirror')}", f"url: {url}/mirror"))
+        subprocess.check_output([constants.XDEB_INSTALL_BINARY_PATH, "sync", "--contents", "local"], env=env)
+
+        assert requests.count("/mirror/dists/stable/Contents-amd64.gz") == 1
+
+    output = subprocess.check_output([constants.XDEB_INSTALL_BINARY_PATH, "-O", "json", "provides", "bin/xdeb-install-local-"], env=env).decode()
+    matches = {match["component"]: match["packages"] for match in json.loads(output)}
+    assert matches == {"main": ["xdeb-install-local-hello"], "contrib": ["xdeb-install-local-extra"]}
+
+
+@pytest.mark.order(36)
+def test_sync_mirror_failover(tmp_path):
+    env = helpers.create_local_mirror(tmp_path, [("xdeb-install-local-hello", "1.0", None)])
+    config = tmp_path.joinpath("config", "xdeb-install", "config.yaml")
+
+    with helpers.serve_http(tmp_path, {"/broken/": 503}) as (url, requests):
+        config.write_text(config.read_text().replace(
+            f"url: {tmp_path.joinpath('mirror')}", f"url: {url}/broken\n    mirrors: [{url}/mirror]"
+        ))
+        output = subprocess.check_output([constants.XDEB_INSTALL_BINARY_PATH, "--retries", "1", "sync", "local"], env=env).decode()
+
+        # server errors are retried before failing over to the next mirror
+        assert f"Retrying {url}/broken/dists/stable/main/binary-amd64/Packages" in output
+        assert f"Mirror {url}/broken failed" in output
+        assert requests.count("/broken/dists/stable/main/binary-amd64/Packages") == 2
+
+        output = subprocess.check_output([constants.XDEB_INSTALL_BINARY_PATH, "search", "xdeb-install-local-hello"], env=env).decode()
+        assert f"{url}/mirror/pool/main/x/xdeb-install-local-hello_1.0_amd64.deb" in output
+
+
+@pytest.mark.order(36)
+def test_sync_no_proxy(tmp_path):
+    env = helpers.create_local_mirror(tmp_path, [("xdeb-install-local-hello", "1.0", None)])
+    config = tmp_path.joinpath("config", "xdeb-install", "config.yaml")
+
+    with helpers.serve_http(tmp_path, {}) as (url, requests):
+        config.write_text(config.read_text().replace(f"url: {tmp_path.joinpath('mirror')}", "url: http://xdeb-install.invalid/mirror"))
+        env = {**env, "HTTP_PROXY": url, "http_proxy": url}
+
+        # the proxy of the environment serves the mirror
+        subprocess.check_call([constants.XDEB_INSTALL_BINARY_PATH, "sync", "local"], env=env)
+        assert "http://xdeb-install.invalid/mirror/dists/stable/main/binary-amd64/Packages" in requests
+
+        # hosts not to use a proxy for apply to proxies of the environment as well
+        config.write_text(config.read_text() + "http:\n  no-proxy: [xdeb-install.invalid]\n")
+
+        with pytest.raises(subprocess.CalledProcessError):
+            subprocess.check_call([constants.XDEB_INSTALL_BINARY_PATH, "--retries", "0", "sync", "local"], env=env)
+
+
+@pytest.mark.order(36)
+def test_sync_redacted(tmp_path):
+    env = helpers.create_local_mirror(tmp_path, [("xdeb-install-local-hello", "1.0", None)])
+    config = tmp_path.joinpath("config", "xdeb-install", "config.yaml")
+
+    with helpers.serve_http(tmp_path, {"/broken/": 503}) as (url, requests):
+        secret_url = url.replace("http://", "http://user:p%40ss-word@")
+        config.write_text(config.read_text().replace(
+            f"url: {tmp_path.joinpath('mirror')}", f"url: {secret_url}/broken\n    mirrors: [{secret_url}/mirror]"
+        ))
+        process = subprocess.run(
+            [constants.XDEB_INSTALL_BINARY_PATH, "--retries", "0", "sync", "local"],
+            stdout=subprocess.PIPE, stderr=subprocess.STDOUT, env=env
+        )
+        output = process.stdout.decode()
+
+        assert process.returncode == 0
+        assert f"Mirror {url.replace('http://', 'http://user:xxxxx@')}/broken failed" in output
+        assert "p%40ss-word" not in output
+        assert "p@ss-word" not in output
+
+
+@pytest.mark.order(36)
+def test_sync_auth_sources(tmp_path):
+    env = helpers.create_local_mirror(tmp_path, [("xdeb-install-local-hello", "1.0", None)])
+    config = tmp_path.joinpath("config", "xdeb-install", "config.yaml")
+    original = config.read_text()
+
+    for auth in ("{}", "{netrc: true, token-env: XDEB_INSTALL_TEST_TOKEN}"):
+        config.write_text(original + f"    auth: {auth}\n")
+        process = subprocess.run(
+            [constants.XDEB_INSTALL_BINARY_PATH, "sync", "local"], stdout=subprocess.PIPE, stderr=subprocess.STDOUT, env=env
+        )
+        output = process.stdout.decode()
+
+        assert process.returncode != 0
+        assert "exactly one of netrc, environment variables or an entry" in output
+        # missing credentials are no failure of the mirror
+        assert "skipping it from now on" not in output
+
+
+@pytest.mark.order(36)
+def test_sync_forbidden(tmp_path):
+    env = helpers.create_local_mirror(tmp_path, [("xdeb-install-local-hello", "1.0", None)])
+    config = tmp_path.joinpath("config", "xdeb-install", "config.yaml")
+
+    with helpers.serve_http(tmp_path, {"/mirror/dists/stable/main/binary-amd64/Packages.xz": 403}) as (url, requests):
+        config.write_text(config.read_text().replace(f"url: {tmp_path.joinpath('mirror')}", f"url: {url}/mirror"))
+
+        # object stores answer 403 for missing files, the next variant is tried without credentials
+        subprocess.check_call([constants.XDEB_INSTALL_BINARY_PATH, "sync", "local"], env=env)
+        assert "/mirror/dists/stable/main/binary-amd64/Packages.gz" in requests
+
+        # credentials sent are rejected however
+        config.write_text(config.read_text() + "    auth:\n      token-env: XDEB_INSTALL_TEST_TOKEN\n")
+        # the variant synced last would be requested first otherwise
+        shutil.rmtree(tmp_path.joinpath("config", "xdeb-install", "repositories"))
+
+        with pytest.raises(subprocess.CalledProcessError):
+            subprocess.check_call(
+                [constants.XDEB_INSTALL_BINARY_PATH, "sync", "local"], env={**env, "XDEB_INSTALL_TEST_TOKEN": "token"}
+            )
+
+
+@pytest.mark.order(36)
+def test_sync_changed_url(tmp_path):
+    env = helpers.create_local_mirror(tmp_path, [("xdeb-install-local-hello", "1.0", None)])
+    config = tmp_path.joinpath("config", "xdeb-install", "config.yaml")
+    subprocess.check_call([constants.XDEB_INSTALL_BINARY_PATH, "sync", "local"], env=env)
+
+    with helpers.serve_http(tmp_path, {}) as (url, requests):
+        config.write_text(config.read_text().replace(f"url: {tmp_path.joinpath('mirror')}", f"url: {url}/mirror"))
+
+        # the very same Packages file from another URL changes the URLs of the packages
+        output = subprocess.check_output([constants.XDEB_INSTALL_BINARY_PATH, "sync", "local"], env=env).decode()
+        assert "1 components updated, 0 unchanged" in output
+
+        output = subprocess.check_output([constants.XDEB_INSTALL_BINARY_PATH, "search", "xdeb-install-local-hello"], env=env).decode()
+        assert f"{url}/mirror/pool/main/x/xdeb-install-local-hello_1.0_amd64.deb" in output
+
+
+@pytest.mark.order(36)
+def test_sync_unauthorized(tmp_path):
+    env = helpers.create_local_mirror(tmp_path, [("xdeb-install-local-hello", "1.0", None)])
+    config = tmp_path.joinpath("config", "xdeb-install", "config.yaml")
+
+    with helpers.serve_http(tmp_path, {"/mirror/": 401}) as (url, requests):
+        config.write_text(config.read_text().replace(f"url: {tmp_path.joinpath('mirror')}", f"url: {url}/mirror"))
+
+        # repositories requiring credentials are no missing files, even if none are configured
+        process = subprocess.run(
+            [constants.XDEB_INSTALL_BINARY_PATH, "sync", "local"], stdout=subprocess.PIPE, stderr=subprocess.STDOUT, env=env
+        )
+        output = process.stdout.decode()
+
+        assert process.returncode != 0
+        assert f"could not download file '{url}/mirror/dists/stable/main/binary-amd64/Packages': 401 Unauthorized" in output
+
+
+@pytest.mark.order(36)
+def test_sync_configured_proxy(tmp_path):
+    env = helpers.create_local_mirror(tmp_path, [("xdeb-install-local-hello", "1.0", None)])
+    config = tmp_path.joinpath("config", "xdeb-install", "config.yaml")
+
+    with helpers.serve_http(tmp_path, {}) as (url, requests):
+        config.write_text(
+            config.read_text().replace(f"url: {tmp_path.joinpath('mirror')}", "url: http://xdeb-install.invalid/mirror")
+            + f"http:\n  proxy: {url}\n"
+        )
+
+        subprocess.check_call([constants.XDEB_INSTALL_BINARY_PATH, "sync", "local"], env=env)
+        assert "http://xdeb-install.invalid/mirror/dists/stable/main/binary-amd64/Packages" in requests
+
+        # NO_PROXY applies to the proxy of the config as well
+        with pytest.raises(subprocess.CalledProcessError):
+            subprocess.check_call(
+                [constants.XDEB_INSTALL_BINARY_PATH, "--retries", "0", "sync", "local"],
+                env={**env, "NO_PROXY": "xdeb-install.invalid", "no_proxy": "xdeb-install.invalid"}
+            )
+
+
+def run_in_terminal(args, env):
+    """
+    Runs the command with stdout and stderr attached to a pseudo terminal, returns its exit code and output.
+    """
+    primary, secondary = pty.openpty()
+    process = subprocess.Popen(args, stdin=subprocess.DEVNULL, stdout=secondary, stderr=secondary, env=env)
+    os.close(secondary)
+    output = b""
+
+    while True:
+        try:
+            data = os.read(primary, 4096)
+        except OSError:
+            break
+
+        if not data:
+            break
+
+        output += data
+
+    os.close(primary)
+    return process.wait(), output.decode()
+
+
+@pytest.mark.order(36)
+def test_sync_progress(tmp_path):
+    env = helpers.create_local_mirror(tmp_path, [("xdeb-install-local-hello", "1.0", None)])
+    config = tmp_path.joinpath("config", "xdeb-install", "config.yaml")
+
+    # slow responses keep the sync running long enough for the progress to be reported
+    with helpers.serve_http(tmp_path, {}, delay=0.5) as (url, _):
+        config.write_text(config.read_text().replace(f"url: {tmp_path.joinpath('mirror')}", f"url: {url}/mirror"))
+
+        returncode, output = run_in_terminal([constants.XDEB_INSTALL_BINARY_PATH, "sync", "local"], env)
+        assert returncode == 0
+        assert "\r\033[K[xdeb-install] Syncing repositories: " in output
+
+        # the status line is replaced by log messages every few seconds if the output is not a terminal
+        output = subprocess.check_output([constants.XDEB_INSTALL_BINARY_PATH, "sync", "local"], env=env).decode()
+        assert "\033[K" not in output
+
+        returncode, output = run_in_terminal([constants.XDEB_INSTALL_BINARY_PATH, "--quiet", "sync", "local"], env)
+        assert returncode == 0
+        assert "Syncing repositories: " not in output
+
+        # structured output is meant for scripts, terminal or not
+        returncode, output = run_in_terminal([constants.XDEB_INSTALL_BINARY_PATH, "-O", "json", "sync", "local"], env)
+        assert returncode == 0
+        assert "Syncing repositories: " not in output
@@ -1,3 +1,5 @@
+import json
+import shutil
 import subprocess
 import pytest
 
@@ -22,6 +24,12 @@ def test_search_speedcrunch():
     helpers.assert_xdeb_install_command("sync")
     helpers.assert_xdeb_install_command("search", "speedcrunch")
 
+    for output in ("json", "yaml", "table"):
+        helpers.assert_xdeb_install_command("--output", output, "search", "speedcrunch")
+
+    with pytest.raises(subprocess.CalledProcessError):
+        helpers.assert_xdeb_install_command("--output", "xml", "search", "speedcrunch")
+
 
 @pytest.mark.order(43)
 def test_search_packages():
@@ -43,3 +51,101 @@ def test_search_packages():
                     continue
 
                 helpers.assert_xdeb_install_command("search", "--provider", provider, "--distribution", distribution, package)
+
+
+@pytest.mark.order(44)
+def test_search_modes():
+    helpers.assert_xdeb_install_command("sync")
+    helpers.assert_xdeb_install_command("search", "--contains", "crunch")
+    helpers.assert_xdeb_install_command("search", "--glob", "speed*")
+    helpers.assert_xdeb_install_command("search", "--regex", "^speedcrunch$")
+    helpers.assert_xdeb_install_command("search", "--latest-only", "--limit", "2", "speedcrunch")
+
+    with pytest.raises(subprocess.CalledProcessError):
+        helpers.assert_xdeb_install_command("search", "--exact", "--regex", "speedcrunch")
+
+    with pytest.raises(subprocess.CalledProcessError):
+        helpers.assert_xdeb_install_command("search", "--regex", "(")
+
+
+@pytest.mark.order(44)
+def test_search_description():
+    helpers.assert_xdeb_install_command("sync")
+    helpers.assert_xdeb_install_command("search", "--description", "calculator")
+    helpers.assert_xdeb_install_command("search", "--description", "--provider", "debian.org", "scientific", "calculator")
+
+    with pytest.raises(subprocess.CalledProcessError):
+        helpers.assert_xdeb_install_command("search", "--description", constants.DEB_NONEXISTENT_PACKAGE)
+
+
+@pytest.mark.order(44)
+def test_search_status():
+    output = subprocess.check_output([constants.XDEB_INSTALL_BINARY_PATH, "--output", "json", "search", "--exact", "speedcrunch"])
+
+    for result in json.loads(output):
+        assert result["status"]["status"] in ("not-installed", "native", "xdeb", "upgradable")
+
+
+@pytest.mark.order(44)
+def test_search_suggestions(tmp_path):
+    env = helpers.create_local_mirror(tmp_path, [
+        ("xdeb-install-calculator", "1.0", None),
+        ("libxdebinstall3", "1.0", None),
+        ("xdebinstalltool", "1.0", None),
+    ])
+
+    subprocess.check_call([constants.XDEB_INSTALL_BINARY_PATH, "sync", "local"], env=env)
+
+    for name, suggestion in (
+        ("xdeb-install-calculater", "xdeb-install-calculator"),  # typo
+        ("libxdebinstall5", "libxdebinstall3"),  # other soname
+        ("xdebinstalltool-bin", "xdebinstalltool"),  # -bin suffix
+    ):
+        process = subprocess.run(
+            [constants.XDEB_INSTALL_BINARY_PATH, "show", name], stdout=subprocess.PIPE, stderr=subprocess.STDOUT, env=env
+        )
+        output = process.stdout.decode()
+
+        assert process.returncode != 0
+        assert "did you mean" in output
+        assert f"{suggestion} (local)" in output
+
+
+@pytest.mark.order(44)
+@pytest.mark.skipif(shutil.which("zstd") is None, reason="zstd is needed to write component files")
+def test_search_component_file_layouts(tmp_path):
+    env = helpers.create_local_mirror(tmp_path, [("xdeb-install-local-hello", "1.0", None)])
+    subprocess.check_call([constants.XDEB_INSTALL_BINARY_PATH, "sync", "local"], env=env)
+    repositories = next(tmp_path.rglob("index.bin")).parent
+
+    # component files written by other means than sync are read without the (now outdated) index
+    layouts = {
+        "quoted": 'sorted: true\nxdeb:\n- name: "xdeb-install-a"\n  version: \'1.0\'\n- name: \'xdeb-install-b\'\n  version: "2:1.0"\n',
+        "multiline": (
+            "sorted: true\nxdeb:\n- name: xdeb-install-a\n  version: \"1.0\"\n  description: |-\n    first line\n"
+            "    - name: xdeb-install-fake\n    xdeb: []\n- name: xdeb-install-b\n  version: \"1.0\"\n  description: >\n"
+            "    folded\n    - name: xdeb-install-fake\n"
+        ),
+        "keyorder": "xdeb:\n- version: \"1.0\"\n  name: xdeb-install-b\n- version: \"1.0\"\n  name: xdeb-install-a\nsorted: true\n",
+        "unsorted": "xdeb:\n- name: xdeb-install-b\n  version: \"1.0\"\n- name: xdeb-install-a\n  version: \"1.0\"\n- name: xdeb-install-a\n  version: \"2.0\"\n",
+    }
+
+    for layout, data in layouts.items():
+        component = repositories.joinpath(f"layout-{layout}", "stable", "main.yaml")
+        component.parent.mkdir(parents=True)
+        component.write_text(data)
+        subprocess.check_call(["zstd", "-q", "--rm", str(component)])
+
+    for name, providers in (
+        ("xdeb-install-a", ["layout-keyorder", "layout-multiline", "layout-quoted", "layout-unsorted", "layout-unsorted"]),
+        ("xdeb-install-b", ["layout-keyorder", "layout-multiline", "layout-quoted", "layout-unsorted"]),
+    ):
+        results = json.loads(subprocess.check_output(
+            [constants.XDEB_INSTALL_BINARY_PATH, "--output", "json", "search", "--exact", name], env=env
+        ))
+
+        assert sorted(result["provider"] for result in results) == providers
+        assert all(result["name"] == name for result in results)
+
+    with pytest.raises(subprocess.CalledProcessError):
+        subprocess.check_call([constants.XDEB_INSTALL_BINARY_PATH, "search", "--exact", "xdeb-install-fake"], env=env)
@@ -0,0 +1,124 @@
+import json
+import subprocess
+import pytest
+
+from . import constants
+from . import helpers
+
+
+@pytest.mark.order(45)
+def test_policy_nothing():
+    with pytest.raises(subprocess.CalledProcessError):
+        helpers.assert_xdeb_install_command("policy")
+
+
+@pytest.mark.order(46)
+def test_policy_nonexistent():
+    with pytest.raises(subprocess.CalledProcessError):
+        helpers.assert_xdeb_install_command("policy", constants.DEB_NONEXISTENT_PACKAGE)
+
+
+@pytest.mark.order(47)
+def test_policy_speedcrunch():
+    helpers.assert_xdeb_install_command("sync")
+    helpers.assert_xdeb_install_command("policy", "speedcrunch")
+    helpers.assert_xdeb_install_command("policy", "--provider", "debian.org", "speedcrunch")
+
+
+def create_policy_mirrors(tmp_path, pins):
+    """
+    Creates providers "first" (1.0) and "second" (2.0) of the same package with the pins given, returns the environment.
+    """
+    config = ""
+
+    for provider, version in (("first", "1.0"), ("second", "2.0")):
+        mirror = tmp_path.joinpath(provider)
+        helpers.create_mirror(mirror, [("xdeb-install-local-hello", version, None)])
+        config += f"  - name: {provider}\n    url: {mirror}\n    architecture: amd64\n    dists: [stable]\n    components: [main]\n"
+
+    env = helpers.create_config(tmp_path, f"providers:\n{config}policy:\n  pins:\n{pins}")
+    subprocess.check_call([constants.XDEB_INSTALL_BINARY_PATH, "sync", "first", "second"], env=env)
+    return env
+
+
+def policy_candidates(env, *args):
+    output = subprocess.check_output(
+        [constants.XDEB_INSTALL_BINARY_PATH, "-O", "json", "policy", *args, "xdeb-install-local-hello"], env=env
+    ).decode()
+    return json.loads(output)
+
+
+def install_local_hello(tmp_path, env, *args):
+    process = subprocess.run(
+        [constants.XDEB_INSTALL_BINARY_PATH, *args, "xdeb-install-local-hello"],
+        input="yes\n".encode(), stdout=subprocess.PIPE, env=env
+    )
+    output = process.stdout.decode()
+
+    assert process.returncode == 0
+    helpers.assert_command_assume_yes(0, ["sudo", "xbps-remove", "xdeb-install-local-hello"])
+
+    # packages of local mirrors are copied from the mirror of the provider chosen
+    return [provider for provider in ("first", "second") if f"Copying {tmp_path.joinpath(provider)}" in output]
+
+
+@pytest.mark.order(47)
+def test_policy_pin_priority(tmp_path):
+    env = create_policy_mirrors(tmp_path, "    - provider: first\n      priority: 900\n")
+
+    # the pinned provider wins over the higher version
+    selected = [candidate for candidate in policy_candidates(env) if candidate["selected"]]
+    assert [candidate["package"]["provider"] for candidate in selected] == ["first"]
+
+    assert install_local_hello(tmp_path, env) == ["first"]
+
+
+@pytest.mark.order(47)
+def test_policy_negative_priority(tmp_path):
+    env = create_policy_mirrors(tmp_path, "    - provider: second\n      priority: -1\n")
+
+    candidates = {candidate["package"]["provider"]: candidate for candidate in policy_candidates(env)}
+    assert candidates["first"]["selected"]
+    assert candidates["second"]["denied"]
+    assert not candidates["second"]["selected"]
+
+    assert install_local_hello(tmp_path, env) == ["first"]
+
+
+@pytest.mark.order(47)
+def test_policy_explicit_provider(tmp_path):
+    env = create_policy_mirrors(tmp_path, "    - provider: second\n      priority: -1\n")
+
+    # negative priorities don't apply to providers asked for explicitly
+    candidates = policy_candidates(env, "--provider", "second")
+    assert [(candidate["package"]["provider"], candidate["selected"], candidate["denied"]) for candidate in candidates] == [
+        ("second", True, False)
+    ]
+
+    assert install_local_hello(tmp_path, env, "--provider", "second") == ["second"]
+
+
+@pytest.mark.order(47)
+def test_policy_candidates(tmp_path):
+    env = create_policy_mirrors(
+        tmp_path, "    - provider: first\n      priority: 900\n    - provider: second\n      priority: 100\n"
+    )
+    candidates = policy_candidates(env)
+
+    # every candidate is listed with its version, priority and the pin it got the priority from
+    assert {candidate["package"]["provider"]: candidate["package"]["version"] for candidate in candidates} == {
+        "first": "1.0", "second": "2.0"
+    }
+    assert {candidate["package"]["provider"]: candidate["priority"] for candidate in candidates} == {
+        "first": 900, "second": 100
+    }
+    assert {candidate["package"]["provider"]: candidate["pin"]["provider"] for candidate in candidates} == {
+        "first": "first", "second": "second"
+    }
+
+    assert candidates[0]["package"]["provider"] == "first"
+    assert candidates[0]["selected"]
+    assert candidates[0]["reason"] == "highest priority and version"
+    assert not candidates[1]["selected"]
+    assert candidates[1]["reason"] == "lower priority (100 < 900)"
+    assert candidates[1]["package"]["url"].endswith("/second/pool/main/x/xdeb-install-local-hello_2.0_amd64.deb")
@@ -0,0 +1,41 @@
+import json
+import subprocess
+import pytest
+
+from . import constants
+from . import helpers
+
+
+@pytest.mark.order(48)
+def test_show_nonexistent():
+    with pytest.raises(subprocess.CalledProcessError):
+        helpers.assert_xdeb_install_command("show", constants.DEB_NONEXISTENT_PACKAGE)
+
+
+@pytest.mark.order(49)
+def test_show_speedcrunch():
+    helpers.assert_xdeb_install_command("sync")
+    helpers.assert_xdeb_install_command("show", "speedcrunch")
+
+    for output in ("json", "yaml"):
+        helpers.assert_xdeb_install_command("--output", output, "show", "speedcrunch")
+
+
+@pytest.mark.order(50)
+def test_show_status_matches_search():
+    show = json.loads(subprocess.check_output([constants.XDEB_INSTALL_BINARY_PATH, "--output", "json", "show", "speedcrunch"]))
+    search = json.loads(subprocess.check_output([constants.XDEB_INSTALL_BINARY_PATH, "--output", "json", "search", "--exact", "speedcrunch"]))
+
+    assert show["status"]["status"] in [result["status"]["status"] for result in search]
+
+
+@pytest.mark.order(50)
+def test_show_json_unescaped(tmp_path):
+    env = helpers.create_local_mirror(tmp_path, [("xdeb-install-local-hello", "1.0", "libc6 (>= 2.34)")])
+    subprocess.check_call([constants.XDEB_INSTALL_BINARY_PATH, "sync", "local"], env=env)
+
+    # version constraints are kept readable instead of being escaped for HTML
+    output = subprocess.check_output(
+        [constants.XDEB_INSTALL_BINARY_PATH, "--output", "json", "show", "xdeb-install-local-hello"], env=env
+    ).decode()
+    assert '"depends": "libc6 (>= 2.34)"' in output
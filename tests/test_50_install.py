@@ -1,3 +1,4 @@
+import subprocess
 import pytest
 
 from . import constants
@@ -38,3 +39,57 @@ def test_install_packages():
                     continue
 
                 helpers.assert_xdeb_install_xbps(0, "--provider", provider, "--distribution", distribution, package)
+
+
+@pytest.mark.order(55)
+def test_install_interactive_without_terminal():
+    # stdin is a pipe, so interactive mode turns itself off
+    helpers.assert_xdeb_install_command("sync")
+    helpers.assert_xdeb_install_xbps(0, "--interactive", "speedcrunch")
+
+
+@pytest.mark.order(56)
+def test_install_local_mirror(tmp_path):
+    env = helpers.create_local_mirror(tmp_path, [("xdeb-install-local-hello", "1.0", None)])
+    subprocess.check_call([constants.XDEB_INSTALL_BINARY_PATH, "sync", "local"], env=env)
+
+    process = subprocess.run(
+        [constants.XDEB_INSTALL_BINARY_PATH, "--provider", "local", "xdeb-install-local-hello"],
+        input="yes\n".encode(), stdout=subprocess.PIPE, env=env
+    )
+    output = process.stdout.decode()
+
+    assert process.returncode == 0
+    # packages of local mirrors are copied instead of downloaded
+    assert f"Copying {tmp_path.joinpath('mirror')}" in output
+    assert "Downloading" not in output
+
+    helpers.assert_command_assume_yes(0, ["sudo", "xbps-remove", "xdeb-install-local-hello"])
+
+
+@pytest.mark.order(57)
+def test_install_mirror_failover(tmp_path):
+    env = helpers.create_local_mirror(tmp_path, [("xdeb-install-local-hello", "1.0", None)])
+    config = tmp_path.joinpath("config", "xdeb-install", "config.yaml")
+    tmp_path.joinpath("backup").symlink_to(tmp_path.joinpath("mirror"))
+    failing = {}
+
+    with helpers.serve_http(tmp_path, failing) as (url, requests):
+        config.write_text(config.read_text().replace(
+            f"url: {tmp_path.joinpath('mirror')}", f"url: {url}/mirror\n    mirrors: [{url}/backup]"
+        ))
+        subprocess.check_call([constants.XDEB_INSTALL_BINARY_PATH, "sync", "local"], env=env)
+
+        # packages missing on a mirror are downloaded from the next one, without skipping the mirror afterwards
+        failing["/mirror/pool/"] = 404
+        process = subprocess.run(
+            [constants.XDEB_INSTALL_BINARY_PATH, "--provider", "local", "xdeb-install-local-hello"],
+            input="yes\n".encode(), stdout=subprocess.PIPE, env=env
+        )
+        output = process.stdout.decode()
+
+        assert process.returncode == 0
+        assert "/backup/pool/main/x/xdeb-install-local-hello_1.0_amd64.deb" in requests
+        assert "skipping it from now on" not in output
+
+    helpers.assert_command_assume_yes(0, ["sudo", "xbps-remove", "xdeb-install-local-hello"])
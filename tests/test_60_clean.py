@@ -1,5 +1,9 @@
+import os
+import subprocess
+import time
 import pytest
 
+from . import constants
 from . import helpers
 
 
@@ -11,3 +15,86 @@ def test_clean():
 @pytest.mark.order(61)
 def test_clean_lists():
     helpers.assert_xdeb_install_command("clean", "--lists")
+
+
+@pytest.mark.order(62)
+def test_clean_cache():
+    helpers.assert_xdeb_install_command("clean", "--cache")
+
+
+def install_local_hello(env):
+    process = subprocess.run(
+        [constants.XDEB_INSTALL_BINARY_PATH, "--provider", "local", "xdeb-install-local-hello"],
+        input="yes\n".encode(), stdout=subprocess.PIPE, env=env
+    )
+
+    assert process.returncode == 0
+    helpers.assert_command_assume_yes(0, ["sudo", "xbps-remove", "xdeb-install-local-hello"])
+    return process.stdout.decode()
+
+
+def create_cache_entry(tmp_path, name, size, age_days):
+    entry = tmp_path.joinpath("cache", "xdeb-install", "debs", "sha256", f"{name * 64}.deb")
+    entry.parent.mkdir(parents=True, exist_ok=True)
+    entry.write_bytes(b"\0" * size)
+
+    mtime = time.time() - age_days * 24 * 60 * 60
+    os.utime(entry, (mtime, mtime))
+    return entry
+
+
+@pytest.mark.order(63)
+def test_cache_reinstall(tmp_path):
+    env = helpers.create_local_mirror(tmp_path, [("xdeb-install-local-hello", "1.0", None)])
+    config = tmp_path.joinpath("config", "xdeb-install", "config.yaml")
+    filename = "/mirror/pool/main/x/xdeb-install-local-hello_1.0_amd64.deb"
+
+    # packages of local mirrors bypass the cache, so the mirror is served via HTTP
+    with helpers.serve_http(tmp_path, {}) as (url, requests):
+        config.write_text(config.read_text().replace(f"url: {tmp_path.joinpath('mirror')}", f"url: {url}/mirror"))
+        subprocess.check_call([constants.XDEB_INSTALL_BINARY_PATH, "sync", "local"], env=env)
+
+        output = install_local_hello(env)
+        assert "Using cached download" not in output
+        assert requests.count(filename) == 1
+
+        output = install_local_hello(env)
+        assert "Using cached download" in output
+        assert requests.count(filename) == 1
+
+
+@pytest.mark.order(63)
+def test_cache_prune_age(tmp_path):
+    env = helpers.create_local_mirror(tmp_path, [("xdeb-install-local-hello", "1.0", None)])
+    config = tmp_path.joinpath("config", "xdeb-install", "config.yaml")
+    config.write_text(config.read_text() + "cache:\n  max-age-days: 10\n")
+
+    old = create_cache_entry(tmp_path, "a", 1024, 11)
+    recent = create_cache_entry(tmp_path, "b", 1024, 9)
+
+    with helpers.serve_http(tmp_path, {}) as (url, _):
+        config.write_text(config.read_text().replace(f"url: {tmp_path.joinpath('mirror')}", f"url: {url}/mirror"))
+        subprocess.check_call([constants.XDEB_INSTALL_BINARY_PATH, "sync", "local"], env=env)
+        install_local_hello(env)
+
+    assert not old.exists()
+    assert recent.exists()
+
+
+@pytest.mark.order(63)
+def test_cache_prune_size(tmp_path):
+    env = helpers.create_local_mirror(tmp_path, [("xdeb-install-local-hello", "1.0", None)])
+    config = tmp_path.joinpath("config", "xdeb-install", "config.yaml")
+    config.write_text(config.read_text() + "cache:\n  max-size-mib: 1\n")
+
+    # the least recently used entries exceeding the size limit are removed
+    newest = create_cache_entry(tmp_path, "a", 600 * 1024, 1)
+    oldest = create_cache_entry(tmp_path, "b", 600 * 1024, 2)
+
+    with helpers.serve_http(tmp_path, {}) as (url, _):
+        config.write_text(config.read_text().replace(f"url: {tmp_path.joinpath('mirror')}", f"url: {url}/mirror"))
+        subprocess.check_call([constants.XDEB_INSTALL_BINARY_PATH, "sync", "local"], env=env)
+        install_local_hello(env)
+
+    assert newest.exists()
+    assert not oldest.exists()
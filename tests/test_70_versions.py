@@ -0,0 +1,24 @@
+import subprocess
+import pytest
+
+from . import constants
+from . import helpers
+
+
+@pytest.mark.order(70)
+def test_versions_nonexistent():
+    with pytest.raises(subprocess.CalledProcessError):
+        helpers.assert_xdeb_install_command("versions", constants.DEB_NONEXISTENT_PACKAGE)
+
+
+@pytest.mark.order(71)
+def test_versions_speedcrunch():
+    helpers.assert_xdeb_install_command("sync")
+    helpers.assert_xdeb_install_command("versions", "speedcrunch")
+    helpers.assert_xdeb_install_command("versions", "--provider", "microsoft.com", "code")
+
+
+@pytest.mark.order(72)
+def test_install_nonexistent_version():
+    helpers.assert_xdeb_install_xbps(1, "--package-version", "0.0.0-0", "speedcrunch")
+    helpers.assert_xdeb_install_xbps(1, "speedcrunch=0.0.0-0")
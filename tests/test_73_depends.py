@@ -0,0 +1,50 @@
+import json
+import subprocess
+import pytest
+
+from . import constants
+from . import helpers
+
+
+@pytest.mark.order(73)
+def test_depends_nonexistent():
+    for command in ("depends", "rdepends"):
+        with pytest.raises(subprocess.CalledProcessError):
+            helpers.assert_xdeb_install_command(command, constants.DEB_NONEXISTENT_PACKAGE)
+
+
+@pytest.mark.order(74)
+def test_depends_speedcrunch():
+    helpers.assert_xdeb_install_command("sync")
+
+    for command in ("depends", "rdepends"):
+        helpers.assert_xdeb_install_command(command, "speedcrunch")
+        helpers.assert_xdeb_install_command(command, "--depth", "1", "--format", "dot", "speedcrunch")
+
+
+@pytest.mark.order(75)
+def test_depends_invalid_format():
+    with pytest.raises(subprocess.CalledProcessError):
+        helpers.assert_xdeb_install_command("depends", "--format", "svg", "speedcrunch")
+
+
+@pytest.mark.order(76)
+def test_depends_version_constraints(tmp_path):
+    env = helpers.create_local_mirror(tmp_path, [
+        ("xdeb-install-local-app", "1.0", "xdeb-install-local-lib (<< 2.0), xdeb-install-local-data (<= 1.0) [amd64] <!nocheck>"),
+        ("xdeb-install-local-lib", "1.5", None),
+        ("xdeb-install-local-data", "1.0", None),
+    ])
+
+    subprocess.check_call([constants.XDEB_INSTALL_BINARY_PATH, "sync", "local"], env=env)
+    tree = json.loads(subprocess.check_output(
+        [constants.XDEB_INSTALL_BINARY_PATH, "--output", "json", "depends", "xdeb-install-local-app"], env=env
+    ))
+
+    constraints = {
+        alternative["name"]: alternative.get("constraint")
+        for child in tree["children"] for alternative in child["alternatives"]
+    }
+
+    # "<" within version constraints must not be taken for the start of a build profile
+    assert constraints == {"xdeb-install-local-lib": "<< 2.0", "xdeb-install-local-data": "<= 1.0"}
@@ -0,0 +1,17 @@
+import subprocess
+import pytest
+
+from . import helpers
+
+
+@pytest.mark.order(76)
+def test_provides_nothing():
+    with pytest.raises(subprocess.CalledProcessError):
+        helpers.assert_xdeb_install_command("provides")
+
+
+@pytest.mark.order(77)
+def test_provides_speedcrunch():
+    helpers.assert_xdeb_install_command("sync", "--contents", "debian.org")
+    helpers.assert_xdeb_install_command("provides", "bin/speedcrunch")
+    helpers.assert_xdeb_install_command("provides", "--regex", "--provider", "debian.org", "bin/speedcrunch$")